@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// enableNWSAlerts turns on active-alert enrichment. Set by ENABLE_NWS_ALERTS.
+var enableNWSAlerts bool
+
+// NWSAlertsResponse is the GeoJSON envelope returned by the NWS active alerts endpoint.
+type NWSAlertsResponse struct {
+	Features []struct {
+		Properties NWSAlert `json:"properties"`
+	} `json:"features"`
+}
+
+// NWSAlert is an active weather alert (severe thunderstorm, flood, etc.) covering an incident.
+type NWSAlert struct {
+	Event    string `json:"event"`
+	Severity string `json:"severity"`
+}
+
+// getActiveAlertsForIncident fetches the NWS alerts currently active at a point.
+func getActiveAlertsForIncident(ctx context.Context, lat, lon float64) ([]NWSAlert, error) {
+	ctx, cancel := context.WithTimeout(ctx, weatherDeadline)
+	defer cancel()
+
+	body, err := fetchNWS(ctx, nwsClient, nwsURL("alerts/active?point="+coordKey(lat, lon)), "alerts")
+	if err != nil {
+		return nil, err
+	}
+	var alertsResponse NWSAlertsResponse
+	if err := json.Unmarshal(body, &alertsResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal NWS alerts JSON: %w", err)
+	}
+	alerts := make([]NWSAlert, 0, len(alertsResponse.Features))
+	for _, f := range alertsResponse.Features {
+		alerts = append(alerts, f.Properties)
+	}
+	return alerts, nil
+}
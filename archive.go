@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rawArchiveDir, when set, receives a gzipped copy of every fetched feed payload.
+// Overridden by RAW_ARCHIVE_DIR.
+var rawArchiveDir string
+
+// rawArchiveKeep is how many archives are kept per source; older ones are pruned. Zero
+// keeps everything. Overridden by RAW_ARCHIVE_KEEP.
+var rawArchiveKeep = 0
+
+// archiveRawPayload gzips body into rawArchiveDir as <source>-<UTC timestamp>.json.gz and
+// prunes that source's oldest archives beyond rawArchiveKeep. The file is written under a
+// temporary name and renamed so a partial archive is never left behind.
+func archiveRawPayload(source string, body []byte) (string, error) {
+	if err := os.MkdirAll(rawArchiveDir, 0o755); err != nil {
+		return "", fmt.Errorf("creating archive directory: %w", err)
+	}
+	prefix := archivePrefix(source)
+	name := filepath.Join(rawArchiveDir, prefix+time.Now().UTC().Format("20060102T150405.000000000Z")+".json.gz")
+
+	tmp, err := os.CreateTemp(rawArchiveDir, ".archive-*")
+	if err != nil {
+		return "", fmt.Errorf("creating archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	zw := gzip.NewWriter(tmp)
+	if _, err := zw.Write(body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("writing archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", fmt.Errorf("renaming archive into place: %w", err)
+	}
+
+	if rawArchiveKeep > 0 {
+		if err := pruneArchives(prefix); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+// archivePrefix turns a source label into a safe file name prefix.
+func archivePrefix(source string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, source) + "-"
+}
+
+// pruneArchives removes all but the newest rawArchiveKeep archives starting with prefix.
+// Timestamps sort lexically, so the oldest archives sort first.
+func pruneArchives(prefix string) error {
+	matches, err := filepath.Glob(filepath.Join(rawArchiveDir, prefix+"*.json.gz"))
+	if err != nil {
+		return err
+	}
+	// A glob on "RWECC-" also matches "RWECC-2-...", so keep only this source's files.
+	var own []string
+	for _, m := range matches {
+		stamp := strings.TrimPrefix(filepath.Base(m), prefix)
+		if len(stamp) > 0 && stamp[0] >= '0' && stamp[0] <= '9' && !strings.Contains(strings.TrimSuffix(stamp, ".json.gz"), "-") {
+			own = append(own, m)
+		}
+	}
+	sort.Strings(own)
+	for len(own) > rawArchiveKeep {
+		if err := os.Remove(own[0]); err != nil {
+			return fmt.Errorf("pruning archive: %w", err)
+		}
+		own = own[1:]
+	}
+	return nil
+}
+
+// readPayloadFile reads a saved payload for --input-file, transparently decompressing
+// archives written by archiveRawPayload.
+func readPayloadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("opening gzipped payload: %w", err)
+	}
+	defer zr.Close()
+	return readLimited(zr)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// createIngestionRunsSQL creates the audit table that records one row per run.
+const createIngestionRunsSQL = `
+	CREATE TABLE IF NOT EXISTS ingestion_runs (
+		id              BIGSERIAL PRIMARY KEY,
+		started_at      TIMESTAMPTZ NOT NULL,
+		finished_at     TIMESTAMPTZ NOT NULL,
+		total_fetched   INTEGER NOT NULL,
+		total_matched   INTEGER NOT NULL,
+		total_saved     INTEGER NOT NULL,
+		weather_errors  INTEGER NOT NULL,
+		error_message   TEXT
+	);
+`
+
+// runStats accumulates what a single run did, across all feeds.
+type runStats struct {
+	StartedAt time.Time
+	Fetched   int
+	Matched   int
+	Saved     int
+	// Weather outcomes, one per processed incident, matching weather_status.
+	WeatherOK         int
+	WeatherNoCoverage int
+	WeatherErrors     int
+	// Processed counts incidents sent for enrichment, which PROCESS_LIMIT caps.
+	Processed int
+
+	// Per-phase timing. Enrichment is the sum of per-incident lookup times, so with a
+	// worker pool it can exceed wall-clock time.
+	FetchDuration  time.Duration
+	EnrichDuration time.Duration
+	DBDuration     time.Duration
+
+	// Per-incident weather lookup latency, over WeatherFetches lookups.
+	WeatherFetches    int
+	WeatherFetchTotal time.Duration
+	WeatherFetchMin   time.Duration
+	WeatherFetchMax   time.Duration
+}
+
+// observeWeatherFetch adds one weather lookup's latency to the run's min/avg/max.
+func (s *runStats) observeWeatherFetch(d time.Duration) {
+	if s.WeatherFetches == 0 || d < s.WeatherFetchMin {
+		s.WeatherFetchMin = d
+	}
+	s.WeatherFetchMax = max(s.WeatherFetchMax, d)
+	s.WeatherFetchTotal += d
+	s.WeatherFetches++
+}
+
+// ensureIngestionRunsTable creates the ingestion_runs table if it doesn't exist.
+func ensureIngestionRunsTable(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, createIngestionRunsSQL); err != nil {
+		return fmt.Errorf("creating ingestion_runs table: %w", err)
+	}
+	return nil
+}
+
+// recordRun inserts an audit row for a finished run. runErr, if any, is stored as the
+// run's top-level error message.
+func recordRun(ctx context.Context, db *sql.DB, stats runStats, runErr error) error {
+	var errMsg sql.NullString
+	if runErr != nil {
+		errMsg = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ingestion_runs (
+			started_at, finished_at, total_fetched, total_matched, total_saved, weather_errors, error_message
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		stats.StartedAt, time.Now(), stats.Fetched, stats.Matched, stats.Saved, stats.WeatherErrors, errMsg)
+	if err != nil {
+		return fmt.Errorf("recording ingestion run: %w", err)
+	}
+	return nil
+}
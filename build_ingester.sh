@@ -2,5 +2,9 @@ set -e
 echo ">>> Pulling latest changes from the Git repository..."
 git pull
 echo ">>> Building the Go application..."
-go build -o rwecc-ingester ingester_rwecc.go
-echo ">>> Build complete! Binary 'rwecc-ingester' is ready."
\ No newline at end of file
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse --short HEAD)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+go build -ldflags "-X main.version=${VERSION} -X main.commit=${COMMIT} -X main.buildDate=${BUILD_DATE}" -o rwecc-ingester .
+echo ">>> Build complete! Binary 'rwecc-ingester' is ready."
+./rwecc-ingester --version
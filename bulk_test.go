@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDetailsAsText(t *testing.T) {
+	args := detailsAsText([]any{"RWECC", []byte(`{"severity":"minor"}`), 35.78})
+	if got, ok := args[1].(string); !ok || got != `{"severity":"minor"}` {
+		t.Errorf("details = %#v, want the JSON as a string", args[1])
+	}
+	if args[0] != "RWECC" || args[2] != 35.78 {
+		t.Errorf("other args = %#v, want them unchanged", args)
+	}
+}
+
+// TestBulkStoreFlush COPYs a row through the staging table into a scratch schema on
+// the Postgres database named by TEST_DATABASE_URL, and checks the merged row.
+func TestBulkStoreFlush(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// One connection, so the search_path below applies to every statement.
+	db.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("rwecc_test_%d", time.Now().UnixNano())
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA "+schema+"; SET search_path TO "+schema); err != nil {
+		t.Fatalf("creating scratch schema: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+	if err := runMigrations(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	prevLoc := incidentLocation
+	incidentLocation = time.UTC
+	t.Cleanup(func() { incidentLocation = prevLoc })
+
+	store := newBulkStore(db)
+	incident := Incident{Jurisdiction: "Raleigh", Problem: "MVC - No Injury", Address: "1 Main St", Lat: 35.78, Long: -78.64, Timestamp: "2024-05-01 10:00:00"}
+	weather := &WeatherData{Temperature: 72, TemperatureUnit: "F", ShortForecast: "Sunny"}
+	if err := store.Save(ctx, enrichedIncident{incident: incident, weather: weather, weatherStatus: weatherStatusOK}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	merged, err := store.Flush(ctx)
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if merged != 1 {
+		t.Fatalf("Flush merged %d rows, want 1", merged)
+	}
+
+	var address, problem, forecast string
+	var temp int
+	err = db.QueryRowContext(ctx, `
+		SELECT address, details->'raw_incident'->>'problem', weather_forecast, weather_temp
+		FROM unified_incidents WHERE source = $1 AND source_id = $2`,
+		incidentSource(incident), sourceIDFor(incident)).Scan(&address, &problem, &forecast, &temp)
+	if err != nil {
+		t.Fatalf("reading merged row: %v", err)
+	}
+	if address != incident.Address || problem != incident.Problem || forecast != "Sunny" || temp != 72 {
+		t.Errorf("merged row = %q, %q, %q, %d; want the saved incident and weather", address, problem, forecast, temp)
+	}
+}
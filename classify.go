@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// problemRegexps holds the compiled form of every /pattern/ filter and rule value. It is
+// filled at startup, before any concurrent use.
+var problemRegexps = make(map[string]*regexp.Regexp)
+
+// regexPattern returns the expression inside a value written /pattern/.
+func regexPattern(v string) (string, bool) {
+	if len(v) >= 2 && strings.HasPrefix(v, "/") && strings.HasSuffix(v, "/") {
+		return v[1 : len(v)-1], true
+	}
+	return "", false
+}
+
+// compileProblemPattern compiles v into problemRegexps if it is written /pattern/.
+// Patterns match case-insensitively, like the substring test. Plain values need nothing.
+func compileProblemPattern(v string) error {
+	expr, ok := regexPattern(v)
+	if !ok {
+		return nil
+	}
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %s: %w", v, err)
+	}
+	problemRegexps[v] = re
+	return nil
+}
+
+// matchesProblem reports whether a normalized problem string matches pattern: as a
+// regular expression for a compiled /pattern/, otherwise as a case-insensitive substring.
+func matchesProblem(problem, pattern string) bool {
+	if re, ok := problemRegexps[pattern]; ok {
+		return re.MatchString(problem)
+	}
+	return strings.Contains(problem, strings.ToUpper(pattern))
+}
+
+// eventTypeRule maps problems containing Match (case-insensitively) to EventType. A Match
+// written /pattern/ is a regular expression instead.
+type eventTypeRule struct {
+	Match     string `json:"match"`
+	EventType string `json:"event_type"`
+}
+
+// severityRule maps problems containing Match (case-insensitively) to Severity. A Match
+// written /pattern/ is a regular expression instead.
+type severityRule struct {
+	Match    string `json:"match"`
+	Severity string `json:"severity"`
+}
+
+// statusRule maps problems containing Match (case-insensitively) to Status, for feeds
+// that encode an incident's lifecycle in the problem text, such as "MVC - CANCELLED". A
+// Match written /pattern/ is a regular expression instead.
+type statusRule struct {
+	Match  string `json:"match"`
+	Status string `json:"status"`
+}
+
+// classificationMap is the JSON shape of the EVENT_TYPE_MAP file.
+type classificationMap struct {
+	EventTypes []eventTypeRule `json:"event_types"`
+	Severities []severityRule  `json:"severities"`
+	Statuses   []statusRule    `json:"statuses"`
+}
+
+// Severity levels, from least to most serious. severityUnknown is used when no rule matches.
+const (
+	severityUnknown  = "unknown"
+	severityMinor    = "minor"
+	severityModerate = "moderate"
+	severitySevere   = "severe"
+	severityFatal    = "fatal"
+)
+
+// validSeverities are the values a severity rule may map to.
+var validSeverities = map[string]bool{
+	severityUnknown: true, severityMinor: true, severityModerate: true, severitySevere: true, severityFatal: true,
+}
+
+// defaultSeverityRules are checked in order, so more specific phrases such as
+// "NO INJURY" must come before the broader "INJURY".
+var defaultSeverityRules = []severityRule{
+	{Match: "FATAL", Severity: severityFatal},
+	{Match: "ENTRAP", Severity: severitySevere},
+	{Match: "PINNED", Severity: severitySevere},
+	{Match: "SERIOUS", Severity: severitySevere},
+	{Match: "NO INJ", Severity: severityMinor},
+	{Match: "PROPERTY", Severity: severityMinor},
+	{Match: "INJ", Severity: severityModerate},
+}
+
+// severityRules is the active rule table, replaced by loadClassificationMap.
+var severityRules = defaultSeverityRules
+
+// defaultEventTypeRules are checked in order; the first match wins.
+var defaultEventTypeRules = []eventTypeRule{
+	{Match: "MVC", EventType: "Vehicle Crash"},
+	{Match: "VEHICLE", EventType: "Vehicle Crash"},
+	{Match: "FIRE", EventType: "Fire"},
+	{Match: "SMOKE", EventType: "Fire"},
+	{Match: "MEDICAL", EventType: "Medical"},
+	{Match: "CARDIAC", EventType: "Medical"},
+	{Match: "BREATHING", EventType: "Medical"},
+	{Match: "UNCONSCIOUS", EventType: "Medical"},
+	{Match: "ASSAULT", EventType: "Assault"},
+	{Match: "SHOOTING", EventType: "Assault"},
+	{Match: "STABBING", EventType: "Assault"},
+}
+
+// eventTypeRules is the active rule table, replaced by loadClassificationMap.
+var eventTypeRules = defaultEventTypeRules
+
+// Values of the status column.
+const (
+	statusActive    = "active"
+	statusResolved  = "resolved"
+	statusCancelled = "cancelled"
+)
+
+// validStatuses are the values a status rule may map to.
+var validStatuses = map[string]bool{statusActive: true, statusResolved: true, statusCancelled: true}
+
+// statusRules are checked in order; the first match wins. There are none by default, so
+// every incident the feed doesn't report closed is active. Set by loadClassificationMap.
+var statusRules []statusRule
+
+// loadClassificationMap reads classification rules from a JSON file.
+func loadClassificationMap(path string) (*classificationMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading classification map: %w", err)
+	}
+	var m classificationMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing classification map %s: %w", path, err)
+	}
+	for i, rule := range m.EventTypes {
+		if rule.Match == "" || rule.EventType == "" {
+			return nil, fmt.Errorf("event_types rule %d in %s needs both match and event_type", i, path)
+		}
+		if err := compileProblemPattern(rule.Match); err != nil {
+			return nil, fmt.Errorf("event_types rule %d in %s: %w", i, path, err)
+		}
+	}
+	for i, rule := range m.Severities {
+		if rule.Match == "" || !validSeverities[rule.Severity] {
+			return nil, fmt.Errorf("severities rule %d in %s needs a match and one of unknown/minor/moderate/severe/fatal", i, path)
+		}
+		if err := compileProblemPattern(rule.Match); err != nil {
+			return nil, fmt.Errorf("severities rule %d in %s: %w", i, path, err)
+		}
+	}
+	for i, rule := range m.Statuses {
+		if rule.Match == "" || !validStatuses[rule.Status] {
+			return nil, fmt.Errorf("statuses rule %d in %s needs a match and one of active/resolved/cancelled", i, path)
+		}
+		if err := compileProblemPattern(rule.Match); err != nil {
+			return nil, fmt.Errorf("statuses rule %d in %s: %w", i, path, err)
+		}
+	}
+	return &m, nil
+}
+
+// unmatchedProblems records the normalized problems already logged as unmatched this
+// run, so each is reported once however many incidents or calls share it.
+var (
+	unmatchedMu       sync.Mutex
+	unmatchedProblems = make(map[string]bool)
+)
+
+// resetUnmatchedProblems starts a new run's set of logged unmatched problems.
+func resetUnmatchedProblems() {
+	unmatchedMu.Lock()
+	unmatchedProblems = make(map[string]bool)
+	unmatchedMu.Unlock()
+}
+
+// classifyEventType maps a raw RWECC problem string to a normalized event_type.
+// Unmatched problems are logged once per run so the table can be extended, and map
+// to "Other".
+func classifyEventType(problem string) string {
+	upper := normalizeField(problem)
+	for _, rule := range eventTypeRules {
+		if matchesProblem(upper, rule.Match) {
+			return rule.EventType
+		}
+	}
+	unmatchedMu.Lock()
+	logged := unmatchedProblems[upper]
+	unmatchedProblems[upper] = true
+	unmatchedMu.Unlock()
+	if !logged {
+		slog.Info("No event type rule matched problem", "problem", problem)
+	}
+	return "Other"
+}
+
+// classifySeverity infers a coarse severity from a raw problem string, such as
+// "MVC - Injury" or "MVC - No Injury", defaulting to unknown.
+func classifySeverity(problem string) string {
+	upper := normalizeField(problem)
+	for _, rule := range severityRules {
+		if matchesProblem(upper, rule.Match) {
+			return rule.Severity
+		}
+	}
+	return severityUnknown
+}
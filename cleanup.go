@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// retentionDays is how long resolved incidents are kept before --cleanup deletes them.
+// Zero, the default, keeps them forever. Overridden by RETENTION_DAYS.
+var retentionDays = 0
+
+// cleanupBatchSize bounds how many rows one DELETE removes, so each statement holds its
+// locks only briefly.
+const cleanupBatchSize = 1000
+
+// purgeResolvedSQL deletes up to $3 of source $1's incidents with status $4 (resolved)
+// older than $2.
+const purgeResolvedSQL = `
+	DELETE FROM unified_incidents WHERE id IN (
+		SELECT id FROM unified_incidents
+		WHERE source = $1 AND status = $4 AND timestamp < $2
+		LIMIT $3
+	);
+`
+
+// countResolvedSQL counts what purgeResolvedSQL would delete, for dry runs.
+const countResolvedSQL = `
+	SELECT count(*) FROM unified_incidents
+	WHERE source = $1 AND status = $3 AND timestamp < $2;
+`
+
+// purgeResolvedIncidents deletes every configured feed's resolved incidents older than
+// retentionDays, cleanupBatchSize rows at a time. Active incidents and other sources'
+// rows are never touched. In dry-run mode it only counts them.
+func purgeResolvedIncidents(ctx context.Context, db *sql.DB) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	var total int64
+	for _, f := range feeds {
+		if dryRun {
+			var n int64
+			if err := db.QueryRowContext(ctx, countResolvedSQL, f.Source, cutoff, statusResolved).Scan(&n); err != nil {
+				return fmt.Errorf("counting resolved incidents: %w", err)
+			}
+			slog.Info("Dry run: would delete resolved incidents", "source", f.Source, "older_than", cutoff, "rows", n)
+			continue
+		}
+		var deleted int64
+		for {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			res, err := db.ExecContext(ctx, purgeResolvedSQL, f.Source, cutoff, cleanupBatchSize, statusResolved)
+			if err != nil {
+				return fmt.Errorf("deleting resolved incidents: %w", err)
+			}
+			n, _ := res.RowsAffected()
+			deleted += n
+			if n < cleanupBatchSize {
+				break
+			}
+		}
+		slog.Info("Deleted resolved incidents", "source", f.Source, "older_than", cutoff, "rows", deleted)
+		total += deleted
+	}
+	if !dryRun {
+		slog.Info("Cleanup complete", "retention_days", retentionDays, "deleted", total)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// clusterThreshold is how many incidents in one area and time window make a cluster
+// worth alerting on, such as a pileup or a sudden storm. Zero disables cluster alerts.
+// Overridden by CLUSTER_THRESHOLD.
+var clusterThreshold = 0
+
+// clusterWindow is the span of incident timestamps a cluster must fit within. Overridden
+// by CLUSTER_WINDOW.
+var clusterWindow = 30 * time.Minute
+
+// clusterPrecision is how many decimal places coordinates are rounded to when grouping;
+// two places is roughly a 1 km cell.
+const clusterPrecision = 2
+
+// clusterIncident is one member of a cluster alert.
+type clusterIncident struct {
+	Address      string `json:"address"`
+	Jurisdiction string `json:"jurisdiction"`
+	Problem      string `json:"problem"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// clusterPayload is the JSON body posted to webhookURL for a cluster.
+type clusterPayload struct {
+	Alert       string            `json:"alert"`
+	Count       int               `json:"count"`
+	Latitude    float64           `json:"latitude"`
+	Longitude   float64           `json:"longitude"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Incidents   []clusterIncident `json:"incidents"`
+}
+
+// clusterMember is a saved incident in the rolling cluster window.
+type clusterMember struct {
+	incident Incident
+	at       time.Time
+}
+
+// clusterRecent holds saved incidents, keyed by seenKey, whose timestamps fall within
+// clusterWindow of the newest one, so clusters can build up over several polls. Unchanged
+// incidents aren't saved again but stay here until they age out. It starts empty after
+// a restart. Runs are sequential, so it needs no locking.
+var clusterRecent = make(map[string]clusterMember)
+
+// clusterAlerted holds the cells currently at or above clusterThreshold, so each
+// cluster is alerted once rather than on every poll while it lasts.
+var clusterAlerted = make(map[string]bool)
+
+// recordClusterIncidents adds saved incidents to clusterRecent and drops those that have
+// fallen more than clusterWindow behind the newest. Incidents whose timestamp can't be
+// parsed are left out.
+func recordClusterIncidents(rows []enrichedIncident) {
+	for _, row := range rows {
+		at, err := parseIncidentTime(row.incident.Timestamp, incidentLocation)
+		if err != nil {
+			continue
+		}
+		clusterRecent[seenKey(row.incident)] = clusterMember{incident: row.incident, at: at}
+	}
+	var newest time.Time
+	for _, m := range clusterRecent {
+		if m.at.After(newest) {
+			newest = m.at
+		}
+	}
+	for key, m := range clusterRecent {
+		if newest.Sub(m.at) > clusterWindow {
+			delete(clusterRecent, key)
+		}
+	}
+}
+
+// findClusters groups clusterRecent by rounded coordinates and, within each cell, finds
+// the most incidents whose timestamps fit in a sliding clusterWindow. It returns the
+// cells that have newly reached clusterThreshold, largest first.
+func findClusters() []clusterPayload {
+	cells := make(map[string][]clusterMember)
+	for _, m := range clusterRecent {
+		point := fmt.Sprintf("%.*f,%.*f", clusterPrecision, m.incident.Lat, clusterPrecision, m.incident.Long)
+		cells[point] = append(cells[point], m)
+	}
+
+	for point := range clusterAlerted {
+		if _, ok := cells[point]; !ok {
+			delete(clusterAlerted, point)
+		}
+	}
+	var clusters []clusterPayload
+	for point, members := range cells {
+		sort.Slice(members, func(i, j int) bool { return members[i].at.Before(members[j].at) })
+		// best is the widest run members[first:first+best] spanning at most clusterWindow.
+		first, best := 0, 0
+		for i, j := 0, 0; j < len(members); j++ {
+			for members[j].at.Sub(members[i].at) > clusterWindow {
+				i++
+			}
+			if j-i+1 > best {
+				first, best = i, j-i+1
+			}
+		}
+		if best < clusterThreshold {
+			delete(clusterAlerted, point)
+			continue
+		}
+		if clusterAlerted[point] {
+			continue
+		}
+		clusterAlerted[point] = true
+
+		start := members[first].at.UTC()
+		payload := clusterPayload{
+			Alert:       "cluster",
+			Count:       best,
+			WindowStart: start,
+			WindowEnd:   start.Add(clusterWindow),
+		}
+		for _, m := range members[first : first+best] {
+			incident := m.incident
+			payload.Latitude += incident.Lat / float64(best)
+			payload.Longitude += incident.Long / float64(best)
+			payload.Incidents = append(payload.Incidents, clusterIncident{
+				Address:      incident.Address,
+				Jurisdiction: incident.Jurisdiction,
+				Problem:      incident.Problem,
+				Timestamp:    incident.Timestamp,
+			})
+		}
+		clusters = append(clusters, payload)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
+
+// notifyClusters adds a run's saved incidents to the rolling window and posts a cluster
+// alert to webhookURL, in the background, for each cluster that has newly formed. Dry
+// runs only log them.
+func notifyClusters(rows []enrichedIncident) {
+	if clusterThreshold == 0 || webhookURL == "" {
+		return
+	}
+	recordClusterIncidents(rows)
+	for _, cluster := range findClusters() {
+		slog.Warn("Incident cluster detected", "count", cluster.Count, "latitude", cluster.Latitude, "longitude", cluster.Longitude, "window_start", cluster.WindowStart)
+		if dryRun {
+			continue
+		}
+		var payload any = cluster
+		if notifyFormat == notifySlack {
+			payload = slackClusterMessage(cluster)
+		}
+		webhookWG.Add(1)
+		go func(cluster clusterPayload) {
+			defer webhookWG.Done()
+			if err := postWebhook(payload); err != nil {
+				slog.Warn("Cluster webhook notification failed", "count", cluster.Count, "window_start", cluster.WindowStart, "error", err)
+			}
+		}(cluster)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// clusterRows builds saved incidents at one point, one per timestamp.
+func clusterRows(lat, lon float64, timestamps ...string) []enrichedIncident {
+	rows := make([]enrichedIncident, len(timestamps))
+	for i, ts := range timestamps {
+		rows[i] = enrichedIncident{incident: Incident{
+			Source: "RWECC", Problem: "MVC", Address: fmt.Sprintf("%d Main St", i+1),
+			Lat: lat, Long: lon, Timestamp: ts,
+		}}
+	}
+	return rows
+}
+
+// resetClusters gives a test an empty rolling window with the given settings.
+func resetClusters(t *testing.T, threshold int, window time.Duration) {
+	t.Helper()
+	prevThreshold, prevWindow, prevLoc := clusterThreshold, clusterWindow, incidentLocation
+	prevRecent, prevAlerted := clusterRecent, clusterAlerted
+	clusterThreshold, clusterWindow, incidentLocation = threshold, window, time.UTC
+	clusterRecent, clusterAlerted = make(map[string]clusterMember), make(map[string]bool)
+	t.Cleanup(func() {
+		clusterThreshold, clusterWindow, incidentLocation = prevThreshold, prevWindow, prevLoc
+		clusterRecent, clusterAlerted = prevRecent, prevAlerted
+	})
+}
+
+func TestFindClustersAcrossBucketBoundary(t *testing.T) {
+	resetClusters(t, 3, 30*time.Minute)
+	// 10:20 to 10:40 straddles the 10:30 boundary of a fixed 30-minute bucket.
+	recordClusterIncidents(clusterRows(35.78, -78.64, "2024-05-01 10:20:00", "2024-05-01 10:29:00", "2024-05-01 10:40:00"))
+	clusters := findClusters()
+	if len(clusters) != 1 || clusters[0].Count != 3 {
+		t.Fatalf("clusters = %+v, want one of 3", clusters)
+	}
+	if want := time.Date(2024, 5, 1, 10, 20, 0, 0, time.UTC); !clusters[0].WindowStart.Equal(want) {
+		t.Errorf("WindowStart = %v, want the first member's time %v", clusters[0].WindowStart, want)
+	}
+}
+
+func TestFindClustersAcrossRuns(t *testing.T) {
+	resetClusters(t, 3, 30*time.Minute)
+	rows := clusterRows(35.78, -78.64, "2024-05-01 10:00:00", "2024-05-01 10:05:00", "2024-05-01 10:10:00", "2024-05-01 10:15:00")
+
+	// Each poll saves only the incidents that are new, as unchanged ones are skipped.
+	recordClusterIncidents(rows[:2])
+	if clusters := findClusters(); len(clusters) != 0 {
+		t.Fatalf("after 2 incidents clusters = %+v, want none", clusters)
+	}
+	recordClusterIncidents(rows[2:3])
+	if clusters := findClusters(); len(clusters) != 1 || clusters[0].Count != 3 {
+		t.Fatalf("after 3 incidents over two polls clusters = %+v, want one of 3", clusters)
+	}
+	recordClusterIncidents(rows[3:])
+	if clusters := findClusters(); len(clusters) != 0 {
+		t.Errorf("clusters = %+v, want the ongoing cluster not alerted again", clusters)
+	}
+}
+
+func TestFindClustersOutsideWindow(t *testing.T) {
+	resetClusters(t, 3, 30*time.Minute)
+	recordClusterIncidents(clusterRows(35.78, -78.64, "2024-05-01 10:00:00", "2024-05-01 10:20:00", "2024-05-01 10:45:00"))
+	if clusters := findClusters(); len(clusters) != 0 {
+		t.Errorf("clusters = %+v, want none for incidents spread over 45 minutes", clusters)
+	}
+	if len(clusterRecent) != 2 {
+		t.Errorf("window holds %d incidents, want the 10:00 one aged out", len(clusterRecent))
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command-line flags. Those that mirror a Config field override both the config file and
+// the environment when given.
+var (
+	versionFlag   = flag.Bool("version", false, "print the version, commit, and build date, then exit")
+	configFlag    = flag.String("config", "", "read configuration from this JSON file; environment variables override its values")
+	dryRunFlag    = flag.Bool("dry-run", false, "fetch, filter, and enrich incidents without writing to the database")
+	limitFlag     = flag.Int("limit", 0, "stop after processing this many matching incidents per run (0 means no limit)")
+	bulkFlag      = flag.Bool("bulk", false, "load incidents with Postgres COPY through a staging table, for large backfills")
+	selftestFlag  = flag.Bool("selftest", false, "check connectivity to the database, the feeds, and NWS, then exit; writes nothing")
+	reenrichFlag  = flag.Bool("reenrich", false, "fetch weather for stored incidents that have none, update their weather columns and details, then exit")
+	reenrichSince = flag.Duration("reenrich-since", 0, "with --reenrich, only consider incidents from within this long ago (0 means all)")
+	cleanupFlag   = flag.Bool("cleanup", false, "delete resolved incidents older than RETENTION_DAYS, then exit")
+	inputFileFlag = flag.String("input-file", "", "read incidents from this saved JSON payload (or a .json.gz from RAW_ARCHIVE_DIR) instead of the live RWECC feed")
+)
+
+// duration is a time.Duration that reads and writes JSON as a string like "30s".
+type duration struct{ time.Duration }
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("durations must be strings like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Config is every setting the ingestor reads at startup. Each field can come from the
+// --config file under its JSON name or from the environment variable noted beside it;
+// the environment wins, and flags win over both.
+type Config struct {
+	LogFormat    string `json:"log_format"`    // LOG_FORMAT
+	LogLevel     string `json:"log_level"`     // LOG_LEVEL: debug, info, warn, or error
+	DryRun       bool   `json:"dry_run"`       // DRY_RUN, --dry-run
+	ProcessLimit int    `json:"process_limit"` // PROCESS_LIMIT, --limit
+	Bulk         bool   `json:"bulk"`          // --bulk
+	InputFile    string `json:"input_file"`    // --input-file
+
+	DBDriver         string   `json:"db_driver"`          // DB_DRIVER: postgres or sqlite
+	DatabaseHost     string   `json:"database_host"`      // DATABASE_HOST
+	DatabasePort     string   `json:"database_port"`      // DATABASE_PORT
+	DatabaseUsername string   `json:"database_username"`  // DATABASE_USERNAME
+	DatabasePassword string   `json:"database_password"`  // DATABASE_PASSWORD
+	DatabaseName     string   `json:"database_name"`      // DATABASE_NAME; the file path for sqlite
+	DBMaxOpen        int      `json:"db_max_open"`        // DB_MAX_OPEN
+	DBMaxIdle        int      `json:"db_max_idle"`        // DB_MAX_IDLE
+	DBConnLifetime   duration `json:"db_conn_lifetime"`   // DB_CONN_LIFETIME
+	DBConnectRetries int      `json:"db_connect_retries"` // DB_CONNECT_RETRIES
+	AdvisoryLockID   int64    `json:"advisory_lock_id"`   // ADVISORY_LOCK_ID
+	RunMigrations    bool     `json:"run_migrations"`     // RUN_MIGRATIONS
+	SkipSchemaCheck  bool     `json:"skip_schema_check"`  // SKIP_SCHEMA_CHECK
+	AnalyticsDSN     string   `json:"analytics_dsn"`      // ANALYTICS_DSN, a secondary database every incident is copied to
+
+	SourceName        string   `json:"source_name"`        // SOURCE_NAME
+	RWECCURLs         string   `json:"rwecc_urls"`         // RWECC_URLS or RWECC_URL
+	RWECCTimeout      duration `json:"rwecc_timeout"`      // RWECC_TIMEOUT
+	RWECCPageSize     int      `json:"rwecc_page_size"`    // RWECC_PAGE_SIZE
+	RWECCMaxPages     int      `json:"rwecc_max_pages"`    // RWECC_MAX_PAGES
+	RWECCMaxRetries   int      `json:"rwecc_max_retries"`  // RWECC_MAX_RETRIES
+	MaxResponseBytes  int64    `json:"max_response_bytes"` // MAX_RESPONSE_BYTES
+	ProxyURL          string   `json:"proxy_url"`          // PROXY_URL; otherwise HTTP_PROXY/HTTPS_PROXY apply
+	IncidentFilters   string   `json:"incident_filters"`   // INCIDENT_FILTERS
+	AddressBlocklist  string   `json:"address_blocklist"`  // ADDRESS_BLOCKLIST
+	JurisdictionAllow string   `json:"jurisdiction_allow"` // JURISDICTION_ALLOW
+	JurisdictionDeny  string   `json:"jurisdiction_deny"`  // JURISDICTION_DENY
+	BBox              string   `json:"bbox"`               // BBOX: minLat,minLong,maxLat,maxLong
+	IncidentTimezone  string   `json:"incident_timezone"`  // INCIDENT_TIMEZONE
+	EventTypeMap      string   `json:"event_type_map"`     // EVENT_TYPE_MAP
+	GeocoderURL       string   `json:"geocoder_url"`       // GEOCODER_URL
+	BatchSize         int      `json:"batch_size"`         // BATCH_SIZE
+	ResolveAfter      int      `json:"resolve_after"`      // RESOLVE_AFTER
+	ResolveEmptyFeed  bool     `json:"resolve_empty_feed"` // RESOLVE_ON_EMPTY_FEED
+	RetentionDays     int      `json:"retention_days"`     // RETENTION_DAYS; 0 keeps resolved incidents forever
+	IncidentHistory   bool     `json:"incident_history"`   // INCIDENT_HISTORY
+	ConflictMode      string   `json:"conflict_mode"`      // CONFLICT_MODE: update or skip
+	DetailsMaxBytes   int      `json:"details_max_bytes"`  // DETAILS_MAX_BYTES; 0 means no cap
+
+	RWECCAuthBearer    string `json:"rwecc_auth_bearer"`     // RWECC_AUTH_BEARER
+	RWECCAuthBasicUser string `json:"rwecc_auth_basic_user"` // RWECC_AUTH_BASIC_USER
+	RWECCAuthBasicPass string `json:"rwecc_auth_basic_pass"` // RWECC_AUTH_BASIC_PASS
+
+	WeatherSource     string   `json:"weather_source"`      // WEATHER_SOURCE: nws, file:<path>, or db
+	FallbackWeather   string   `json:"fallback_weather"`    // FALLBACK_WEATHER: empty or openweathermap
+	OpenWeatherMapKey string   `json:"openweathermap_key"`  // OPENWEATHERMAP_API_KEY
+	NWSBaseURL        string   `json:"nws_base_url"`        // NWS_BASE_URL
+	NWSUserAgent      string   `json:"nws_user_agent"`      // NWS_USER_AGENT, with contact info
+	WeatherUnits      string   `json:"weather_units"`       // WEATHER_UNITS
+	WeatherMaxRetries int      `json:"weather_max_retries"` // WEATHER_MAX_RETRIES
+	WeatherWorkers    int      `json:"weather_workers"`     // WEATHER_WORKERS
+	WeatherCacheTTL   duration `json:"weather_cache_ttl"`   // WEATHER_CACHE_TTL
+	CoordPrecision    int      `json:"coord_precision"`     // COORD_PRECISION
+	NWSRateLimit      float64  `json:"nws_rate_limit"`      // NWS_RATE_LIMIT, requests per second; 0 disables
+	TempMinF          float64  `json:"temp_min_f"`          // TEMP_MIN_F
+	TempMaxF          float64  `json:"temp_max_f"`          // TEMP_MAX_F
+	EnableNWSAlerts   bool     `json:"enable_nws_alerts"`   // ENABLE_NWS_ALERTS
+	EnableWeather     bool     `json:"enable_weather"`      // ENABLE_WEATHER
+
+	WebhookURL       string   `json:"webhook_url"`       // WEBHOOK_URL
+	NotifyFormat     string   `json:"notify_format"`     // NOTIFY_FORMAT: json or slack
+	BrokerURL        string   `json:"broker_url"`        // BROKER_URL, nats:// or kafka://
+	BrokerTopic      string   `json:"broker_topic"`      // BROKER_TOPIC
+	GeoJSONOut       string   `json:"geojson_out"`       // GEOJSON_OUT
+	ClusterThreshold int      `json:"cluster_threshold"` // CLUSTER_THRESHOLD; 0 disables cluster alerts
+	ClusterWindow    duration `json:"cluster_window"`    // CLUSTER_WINDOW
+	RawArchiveDir    string   `json:"raw_archive_dir"`   // RAW_ARCHIVE_DIR
+	RawArchiveKeep   int      `json:"raw_archive_keep"`  // RAW_ARCHIVE_KEEP
+
+	PollInterval  duration `json:"poll_interval"`   // POLL_INTERVAL
+	PollJitter    float64  `json:"poll_jitter"`     // POLL_JITTER, a fraction of POLL_INTERVAL
+	RunTimeout    duration `json:"run_timeout"`     // RUN_TIMEOUT; 0 means no limit
+	SeenCacheSize int      `json:"seen_cache_size"` // SEEN_CACHE_SIZE; 0 disables the seen cache
+	SeenCacheTTL  duration `json:"seen_cache_ttl"`  // SEEN_CACHE_TTL
+	ShutdownGrace duration `json:"shutdown_grace"`  // SHUTDOWN_GRACE
+	MetricsAddr   string   `json:"metrics_addr"`    // METRICS_ADDR
+	HealthAddr    string   `json:"health_addr"`     // HEALTH_ADDR
+	DebugAddr     string   `json:"debug_addr"`      // DEBUG_ADDR
+	OTLPEndpoint  string   `json:"otlp_endpoint"`   // OTEL_EXPORTER_OTLP_ENDPOINT
+}
+
+// defaultConfig returns the settings used when neither the config file nor the
+// environment sets a value. Defaults owned by other files are read from their globals.
+func defaultConfig() Config {
+	return Config{
+		DBDriver:          dbDriver,
+		DBMaxOpen:         10,
+		DBMaxIdle:         5,
+		DBConnLifetime:    duration{5 * time.Minute},
+		DBConnectRetries:  5,
+		SourceName:        sourceName,
+		RWECCTimeout:      duration{rweccClient.Timeout},
+		RWECCMaxPages:     feedMaxPages,
+		RWECCMaxRetries:   rweccMaxRetries,
+		MaxResponseBytes:  maxResponseBytes,
+		IncidentTimezone:  "America/New_York",
+		GeocoderURL:       geocoderURL,
+		BatchSize:         batchSize,
+		ResolveAfter:      resolveAfter,
+		ConflictMode:      conflictMode,
+		WeatherSource:     "nws",
+		NWSBaseURL:        nwsBaseURL,
+		NWSUserAgent:      nwsUserAgent,
+		WeatherUnits:      weatherUnits,
+		WeatherMaxRetries: weatherMaxRetries,
+		WeatherWorkers:    weatherWorkers,
+		WeatherCacheTTL:   duration{24 * time.Hour},
+		EnableWeather:     enableWeather,
+		CoordPrecision:    coordPrecision,
+		NWSRateLimit:      float64(nwsLimiter.Limit()),
+		TempMinF:          tempMinF,
+		TempMaxF:          tempMaxF,
+		RawArchiveKeep:    rawArchiveKeep,
+		BrokerTopic:       publishTopic,
+		ClusterWindow:     duration{clusterWindow},
+		NotifyFormat:      notifyFormat,
+		PollJitter:        pollJitter,
+		SeenCacheSize:     10000,
+		SeenCacheTTL:      duration{10 * time.Minute},
+		ShutdownGrace:     duration{10 * time.Second},
+	}
+}
+
+// LoadConfig builds the effective configuration: defaults, then the JSON file at path
+// (if any), then environment variables, then command-line flags. It validates the
+// result and reports every problem at once.
+func LoadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	var env envReader
+	env.str("LOG_FORMAT", &cfg.LogFormat)
+	env.str("LOG_LEVEL", &cfg.LogLevel)
+	env.boolean("DRY_RUN", &cfg.DryRun)
+	env.integer("PROCESS_LIMIT", &cfg.ProcessLimit)
+	env.str("DB_DRIVER", &cfg.DBDriver)
+	env.str("DATABASE_HOST", &cfg.DatabaseHost)
+	env.str("DATABASE_PORT", &cfg.DatabasePort)
+	env.str("DATABASE_USERNAME", &cfg.DatabaseUsername)
+	env.str("DATABASE_PASSWORD", &cfg.DatabasePassword)
+	env.str("DATABASE_NAME", &cfg.DatabaseName)
+	env.integer("DB_MAX_OPEN", &cfg.DBMaxOpen)
+	env.integer("DB_MAX_IDLE", &cfg.DBMaxIdle)
+	env.duration("DB_CONN_LIFETIME", &cfg.DBConnLifetime)
+	env.integer("DB_CONNECT_RETRIES", &cfg.DBConnectRetries)
+	env.int64("ADVISORY_LOCK_ID", &cfg.AdvisoryLockID)
+	env.boolean("RUN_MIGRATIONS", &cfg.RunMigrations)
+	env.boolean("SKIP_SCHEMA_CHECK", &cfg.SkipSchemaCheck)
+	env.str("ANALYTICS_DSN", &cfg.AnalyticsDSN)
+	env.str("SOURCE_NAME", &cfg.SourceName)
+	env.str("RWECC_URL", &cfg.RWECCURLs)
+	env.str("RWECC_URLS", &cfg.RWECCURLs)
+	env.duration("RWECC_TIMEOUT", &cfg.RWECCTimeout)
+	env.str("RWECC_AUTH_BEARER", &cfg.RWECCAuthBearer)
+	env.str("RWECC_AUTH_BASIC_USER", &cfg.RWECCAuthBasicUser)
+	env.str("RWECC_AUTH_BASIC_PASS", &cfg.RWECCAuthBasicPass)
+	env.integer("RWECC_PAGE_SIZE", &cfg.RWECCPageSize)
+	env.integer("RWECC_MAX_PAGES", &cfg.RWECCMaxPages)
+	env.integer("RWECC_MAX_RETRIES", &cfg.RWECCMaxRetries)
+	env.int64("MAX_RESPONSE_BYTES", &cfg.MaxResponseBytes)
+	env.str("PROXY_URL", &cfg.ProxyURL)
+	env.str("INCIDENT_FILTERS", &cfg.IncidentFilters)
+	env.str("JURISDICTION_ALLOW", &cfg.JurisdictionAllow)
+	env.str("JURISDICTION_DENY", &cfg.JurisdictionDeny)
+	env.str("BBOX", &cfg.BBox)
+	env.str("ADDRESS_BLOCKLIST", &cfg.AddressBlocklist)
+	env.str("INCIDENT_TIMEZONE", &cfg.IncidentTimezone)
+	env.str("EVENT_TYPE_MAP", &cfg.EventTypeMap)
+	env.str("GEOCODER_URL", &cfg.GeocoderURL)
+	env.integer("BATCH_SIZE", &cfg.BatchSize)
+	env.integer("RESOLVE_AFTER", &cfg.ResolveAfter)
+	env.boolean("RESOLVE_ON_EMPTY_FEED", &cfg.ResolveEmptyFeed)
+	env.integer("RETENTION_DAYS", &cfg.RetentionDays)
+	env.boolean("INCIDENT_HISTORY", &cfg.IncidentHistory)
+	env.str("CONFLICT_MODE", &cfg.ConflictMode)
+	env.integer("DETAILS_MAX_BYTES", &cfg.DetailsMaxBytes)
+	env.str("WEATHER_SOURCE", &cfg.WeatherSource)
+	env.str("FALLBACK_WEATHER", &cfg.FallbackWeather)
+	env.str("OPENWEATHERMAP_API_KEY", &cfg.OpenWeatherMapKey)
+	env.str("NWS_BASE_URL", &cfg.NWSBaseURL)
+	env.str("NWS_USER_AGENT", &cfg.NWSUserAgent)
+	env.str("WEATHER_UNITS", &cfg.WeatherUnits)
+	env.integer("WEATHER_MAX_RETRIES", &cfg.WeatherMaxRetries)
+	env.integer("WEATHER_WORKERS", &cfg.WeatherWorkers)
+	env.duration("WEATHER_CACHE_TTL", &cfg.WeatherCacheTTL)
+	env.integer("COORD_PRECISION", &cfg.CoordPrecision)
+	env.float("NWS_RATE_LIMIT", &cfg.NWSRateLimit)
+	env.float("TEMP_MIN_F", &cfg.TempMinF)
+	env.float("TEMP_MAX_F", &cfg.TempMaxF)
+	env.boolean("ENABLE_NWS_ALERTS", &cfg.EnableNWSAlerts)
+	env.boolean("ENABLE_WEATHER", &cfg.EnableWeather)
+	env.str("WEBHOOK_URL", &cfg.WebhookURL)
+	env.str("NOTIFY_FORMAT", &cfg.NotifyFormat)
+	env.str("BROKER_URL", &cfg.BrokerURL)
+	env.str("BROKER_TOPIC", &cfg.BrokerTopic)
+	env.str("GEOJSON_OUT", &cfg.GeoJSONOut)
+	env.integer("CLUSTER_THRESHOLD", &cfg.ClusterThreshold)
+	env.duration("CLUSTER_WINDOW", &cfg.ClusterWindow)
+	env.str("RAW_ARCHIVE_DIR", &cfg.RawArchiveDir)
+	env.integer("RAW_ARCHIVE_KEEP", &cfg.RawArchiveKeep)
+	env.duration("POLL_INTERVAL", &cfg.PollInterval)
+	env.float("POLL_JITTER", &cfg.PollJitter)
+	env.duration("RUN_TIMEOUT", &cfg.RunTimeout)
+	env.integer("SEEN_CACHE_SIZE", &cfg.SeenCacheSize)
+	env.duration("SEEN_CACHE_TTL", &cfg.SeenCacheTTL)
+	env.duration("SHUTDOWN_GRACE", &cfg.ShutdownGrace)
+	env.str("METRICS_ADDR", &cfg.MetricsAddr)
+	env.str("HEALTH_ADDR", &cfg.HealthAddr)
+	env.str("DEBUG_ADDR", &cfg.DebugAddr)
+	env.str("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.OTLPEndpoint)
+
+	cfg.DryRun = cfg.DryRun || *dryRunFlag
+	if *limitFlag > 0 {
+		cfg.ProcessLimit = *limitFlag
+	}
+	cfg.Bulk = cfg.Bulk || *bulkFlag
+	if *inputFileFlag != "" {
+		cfg.InputFile = *inputFileFlag
+	}
+
+	cfg.WeatherUnits = strings.ToLower(cfg.WeatherUnits)
+	cfg.ConflictMode = strings.ToLower(cfg.ConflictMode)
+	cfg.DBDriver = strings.ToLower(cfg.DBDriver)
+	cfg.NotifyFormat = strings.ToLower(cfg.NotifyFormat)
+	cfg.FallbackWeather = strings.ToLower(cfg.FallbackWeather)
+
+	errs := append(env.errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &cfg, nil
+}
+
+// validate checks required settings and value ranges.
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	_, levelErr := parseLogLevel(c.LogLevel)
+	check(levelErr == nil, "LOG_LEVEL: %v", levelErr)
+	check(c.InputFile != "" || strings.TrimSpace(c.RWECCURLs) != "", "RWECC_URL or RWECC_URLS must be set")
+	check(c.ProcessLimit >= 0, "PROCESS_LIMIT must be a non-negative integer, got %d", c.ProcessLimit)
+	check(c.DBMaxOpen >= 1, "DB_MAX_OPEN must be a positive integer, got %d", c.DBMaxOpen)
+	check(c.DBMaxIdle >= 0, "DB_MAX_IDLE must be a non-negative integer, got %d", c.DBMaxIdle)
+	check(c.DBConnLifetime.Duration >= 0, "DB_CONN_LIFETIME must be a non-negative duration, got %s", c.DBConnLifetime)
+	check(c.DBConnectRetries >= 0, "DB_CONNECT_RETRIES must be a non-negative integer, got %d", c.DBConnectRetries)
+	// The advisory lock pins one connection for the whole run, so saves need another.
+	check(c.AdvisoryLockID == 0 || c.DBMaxOpen >= 2, "DB_MAX_OPEN must be at least 2 when ADVISORY_LOCK_ID is set")
+	check(strings.TrimSpace(c.SourceName) != "", "SOURCE_NAME must not be empty")
+	check(c.RWECCAuthBearer == "" || c.RWECCAuthBasicUser == "", "set either RWECC_AUTH_BEARER or RWECC_AUTH_BASIC_USER, not both")
+	check(c.RWECCAuthBasicPass == "" || c.RWECCAuthBasicUser != "", "RWECC_AUTH_BASIC_PASS requires RWECC_AUTH_BASIC_USER")
+	check(c.RWECCTimeout.Duration > 0, "RWECC_TIMEOUT must be a positive duration, got %s", c.RWECCTimeout)
+	check(c.RWECCPageSize >= 0, "RWECC_PAGE_SIZE must be a non-negative integer, got %d", c.RWECCPageSize)
+	check(c.RWECCMaxPages >= 1, "RWECC_MAX_PAGES must be a positive integer, got %d", c.RWECCMaxPages)
+	check(c.MaxResponseBytes > 0, "MAX_RESPONSE_BYTES must be a positive integer, got %d", c.MaxResponseBytes)
+	check(c.BatchSize >= 0, "BATCH_SIZE must be a non-negative integer, got %d", c.BatchSize)
+	check(c.ResolveAfter >= 1, "RESOLVE_AFTER must be a positive integer, got %d", c.ResolveAfter)
+	check(c.RetentionDays >= 0, "RETENTION_DAYS must be a non-negative integer, got %d", c.RetentionDays)
+	check(c.WeatherUnits == "us" || c.WeatherUnits == "si", "WEATHER_UNITS must be \"us\" or \"si\", got %q", c.WeatherUnits)
+	check(c.DetailsMaxBytes >= 0, "DETAILS_MAX_BYTES must be a non-negative integer, got %d", c.DetailsMaxBytes)
+	check(c.RWECCMaxRetries >= 0, "RWECC_MAX_RETRIES must be a non-negative integer, got %d", c.RWECCMaxRetries)
+	check(c.WeatherMaxRetries >= 0, "WEATHER_MAX_RETRIES must be a non-negative integer, got %d", c.WeatherMaxRetries)
+	check(c.WeatherWorkers >= 1, "WEATHER_WORKERS must be a positive integer, got %d", c.WeatherWorkers)
+	check(c.CoordPrecision >= 0 && c.CoordPrecision <= 4, "COORD_PRECISION must be between 0 and 4, got %d", c.CoordPrecision)
+	check(c.NWSRateLimit >= 0, "NWS_RATE_LIMIT must be a non-negative number, got %g", c.NWSRateLimit)
+	check(c.DBDriver == driverPostgres || c.DBDriver == driverSQLite, "DB_DRIVER must be \"postgres\" or \"sqlite\", got %q", c.DBDriver)
+	if c.DBDriver == driverSQLite {
+		check(c.DatabaseName != "", "DATABASE_NAME must name the SQLite file when DB_DRIVER=sqlite")
+		check(!c.Bulk, "--bulk requires DB_DRIVER=postgres")
+		check(!c.IncidentHistory, "INCIDENT_HISTORY requires DB_DRIVER=postgres")
+		check(c.AdvisoryLockID == 0, "ADVISORY_LOCK_ID requires DB_DRIVER=postgres")
+		check(c.WeatherSource != "db", "WEATHER_SOURCE=db requires DB_DRIVER=postgres")
+	}
+	check(c.ConflictMode == conflictUpdate || c.ConflictMode == conflictSkip, "CONFLICT_MODE must be \"update\" or \"skip\", got %q", c.ConflictMode)
+	check(!c.IncidentHistory || c.ConflictMode == conflictUpdate, "INCIDENT_HISTORY requires CONFLICT_MODE=update, since skipped incidents never change")
+	check(strings.TrimSpace(c.NWSUserAgent) != "", "NWS_USER_AGENT must not be empty")
+	check(c.TempMinF < c.TempMaxF, "TEMP_MIN_F must be below TEMP_MAX_F, got %g and %g", c.TempMinF, c.TempMaxF)
+	check(c.WeatherCacheTTL.Duration > 0, "WEATHER_CACHE_TTL must be a positive duration, got %s", c.WeatherCacheTTL)
+	check(c.BrokerURL == "" || c.BrokerTopic != "", "BROKER_TOPIC must be set when BROKER_URL is")
+	check(c.NotifyFormat == notifyJSON || c.NotifyFormat == notifySlack, "NOTIFY_FORMAT must be \"json\" or \"slack\", got %q", c.NotifyFormat)
+	check(c.ClusterThreshold >= 0, "CLUSTER_THRESHOLD must be a non-negative integer, got %d", c.ClusterThreshold)
+	check(c.ClusterWindow.Duration > 0, "CLUSTER_WINDOW must be a positive duration, got %s", c.ClusterWindow)
+	check(c.ClusterThreshold == 0 || c.WebhookURL != "", "CLUSTER_THRESHOLD requires WEBHOOK_URL")
+	check(c.RawArchiveKeep >= 0, "RAW_ARCHIVE_KEEP must be a non-negative integer, got %d", c.RawArchiveKeep)
+	check(c.PollInterval.Duration >= 0, "POLL_INTERVAL must be a non-negative duration, got %s", c.PollInterval)
+	check(c.PollJitter >= 0 && c.PollJitter < 1, "POLL_JITTER must be at least 0 and less than 1, got %g", c.PollJitter)
+	check(c.SeenCacheSize >= 0, "SEEN_CACHE_SIZE must be a non-negative integer, got %d", c.SeenCacheSize)
+	check(c.SeenCacheTTL.Duration > 0, "SEEN_CACHE_TTL must be a positive duration, got %s", c.SeenCacheTTL)
+	check(c.RunTimeout.Duration >= 0, "RUN_TIMEOUT must be a non-negative duration, got %s", c.RunTimeout)
+	check(c.ShutdownGrace.Duration > 0, "SHUTDOWN_GRACE must be a positive duration, got %s", c.ShutdownGrace)
+	return errs
+}
+
+// LogValue logs the configuration with the database password, the analytics DSN, the
+// OpenWeatherMap API key, the feed credentials, the webhook URL, and any broker or proxy
+// URL credentials redacted. Feed and geocoder URLs are logged without query strings.
+func (c Config) LogValue() slog.Value {
+	type plain Config // drops the LogValue method so slog doesn't recurse
+	p := plain(c)
+	if p.DatabasePassword != "" {
+		p.DatabasePassword = "REDACTED"
+	}
+	if p.AnalyticsDSN != "" {
+		p.AnalyticsDSN = "REDACTED"
+	}
+	if p.OpenWeatherMapKey != "" {
+		p.OpenWeatherMapKey = "REDACTED"
+	}
+	if p.RWECCAuthBearer != "" {
+		p.RWECCAuthBearer = "REDACTED"
+	}
+	if p.RWECCAuthBasicPass != "" {
+		p.RWECCAuthBasicPass = "REDACTED"
+	}
+	if p.WebhookURL != "" {
+		// Slack incoming-webhook URLs, among others, are bearer secrets.
+		p.WebhookURL = "REDACTED"
+	}
+	p.GeocoderURL = redactURL(p.GeocoderURL)
+	p.RWECCURLs = redactFeedURLs(p.RWECCURLs)
+	if u, err := url.Parse(p.BrokerURL); err == nil && u.User != nil {
+		p.BrokerURL = u.Redacted()
+	}
+	if u, err := url.Parse(p.ProxyURL); err == nil && u.User != nil {
+		p.ProxyURL = u.Redacted()
+	}
+	return slog.AnyValue(p)
+}
+
+// redactURL strips the credentials and query string from raw, where API keys and tokens
+// usually travel. A value that doesn't parse is redacted whole.
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.Redacted()
+}
+
+// redactFeedURLs applies redactURL to each entry of RWECC_URLS, keeping any LABEL= prefix.
+func redactFeedURLs(v string) string {
+	entries := strings.Split(v, ",")
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if label, rest, ok := strings.Cut(entry, "="); ok && !strings.ContainsAny(label, ":/?") {
+			entries[i] = label + "=" + redactURL(strings.TrimSpace(rest))
+		} else {
+			entries[i] = redactURL(entry)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// envReader applies set environment variables over config values, collecting parse errors.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) str(name string, dst *string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+func (r *envReader) integer(name string, dst *int) {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be an integer, got %q", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) int64(name string, dst *int64) {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be a 64-bit integer, got %q", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) float(name string, dst *float64) {
+	if v := os.Getenv(name); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be a number, got %q", name, v))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (r *envReader) boolean(name string, dst *bool) {
+	if v := os.Getenv(name); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be a boolean, got %q", name, v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) duration(name string, dst *duration) {
+	if v := os.Getenv(name); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be a duration like \"30s\", got %q", name, v))
+			return
+		}
+		dst.Duration = d
+	}
+}
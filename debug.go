@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// debugIncident is one incident as seen by the last run, for /debug/incidents.
+type debugIncident struct {
+	Source       string `json:"source"`
+	SourceID     string `json:"source_id"`
+	Problem      string `json:"problem"`
+	Address      string `json:"address"`
+	Jurisdiction string `json:"jurisdiction"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// debugRun is what the last run fetched and what it did with each incident.
+type debugRun struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Fetched    map[string]int  `json:"fetched"`
+	Matched    []debugIncident `json:"matched"`
+	Skipped    []debugIncident `json:"skipped"`
+	// NoWeather lists matched incidents that were saved without a weather lookup.
+	NoWeather []debugIncident `json:"no_weather"`
+	// Unchanged and LimitSkipped count matched incidents that weren't reprocessed.
+	Unchanged    int `json:"unchanged"`
+	LimitSkipped int `json:"limit_skipped"`
+}
+
+// runDebugger collects a debugRun while a run is in progress and keeps the last finished
+// one for the debug endpoint. It records nothing unless enabled, so runs without
+// DEBUG_ADDR pay no cost.
+type runDebugger struct {
+	mu      sync.Mutex
+	enabled bool
+	current *debugRun
+	last    *debugRun
+}
+
+// runDebug is the process-wide recorder fed by runOnce and processFeed.
+var runDebug runDebugger
+
+func newDebugIncident(incident Incident, reason string) debugIncident {
+	return debugIncident{
+		Source:       incident.Source,
+		SourceID:     sourceIDFor(incident),
+		Problem:      incident.Problem,
+		Address:      incident.Address,
+		Jurisdiction: incident.Jurisdiction,
+		Reason:       reason,
+	}
+}
+
+// record applies fn to the run in progress, if recording.
+func (d *runDebugger) record(fn func(*debugRun)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.enabled && d.current != nil {
+		fn(d.current)
+	}
+}
+
+// Begin starts recording a new run.
+func (d *runDebugger) Begin() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.enabled {
+		d.current = &debugRun{StartedAt: time.Now(), Fetched: make(map[string]int)}
+	}
+}
+
+// Finish publishes the run in progress as the last run.
+func (d *runDebugger) Finish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.current != nil {
+		d.current.FinishedAt = time.Now()
+		d.last, d.current = d.current, nil
+	}
+}
+
+func (d *runDebugger) Fetched(source string, n int) {
+	d.record(func(r *debugRun) { r.Fetched[source] += n })
+}
+
+func (d *runDebugger) Matched(incident Incident) {
+	d.record(func(r *debugRun) { r.Matched = append(r.Matched, newDebugIncident(incident, "")) })
+}
+
+func (d *runDebugger) Skipped(incident Incident, reason string) {
+	d.record(func(r *debugRun) { r.Skipped = append(r.Skipped, newDebugIncident(incident, reason)) })
+}
+
+func (d *runDebugger) NoWeather(incident Incident, reason string) {
+	d.record(func(r *debugRun) { r.NoWeather = append(r.NoWeather, newDebugIncident(incident, reason)) })
+}
+
+func (d *runDebugger) Counts(unchanged, limitSkipped int) {
+	d.record(func(r *debugRun) {
+		r.Unchanged += unchanged
+		r.LimitSkipped += limitSkipped
+	})
+}
+
+// startDebugServer enables run recording and serves the last run as JSON at
+// /debug/incidents on addr in the background.
+func startDebugServer(addr string) {
+	runDebug.mu.Lock()
+	runDebug.enabled = true
+	runDebug.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/incidents", func(w http.ResponseWriter, r *http.Request) {
+		runDebug.mu.Lock()
+		last := runDebug.last
+		data, err := json.MarshalIndent(last, "", "  ")
+		runDebug.mu.Unlock()
+		if last == nil {
+			http.Error(w, "no run has completed yet", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	go func() {
+		slog.Info("Serving debug endpoint", "addr", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("Debug server stopped", "addr", addr, "error", err)
+		}
+	}()
+}
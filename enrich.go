@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// enableWeather turns NWS enrichment (weather and alerts) on. With it off, incidents are
+// saved straight away with null weather, e.g. during a known NWS outage. Overridden by
+// ENABLE_WEATHER.
+var enableWeather = true
+
+// weatherWorkers is the number of concurrent NWS lookups. Overridden by WEATHER_WORKERS.
+var weatherWorkers = 4
+
+// Values of the weather_status column, explaining why weather is or isn't present.
+const (
+	weatherStatusOK         = "ok"
+	weatherStatusNoCoverage = "no_coverage"
+	weatherStatusError      = "error"
+	weatherStatusDisabled   = "disabled"
+)
+
+// enrichedIncident pairs an incident with the weather and alerts fetched for it, either
+// of which may be nil. weatherErr records why the weather lookup failed, if it did, and
+// weatherStatus classifies the outcome for the weather_status column.
+type enrichedIncident struct {
+	incident      Incident
+	weather       *WeatherData
+	alerts        []NWSAlert
+	weatherErr    error
+	weatherStatus string
+	// enrichDuration is how long this incident's weather and alert lookups took.
+	enrichDuration time.Duration
+	// weatherDuration is how long the weather lookup alone took; zero if none was made.
+	weatherDuration time.Duration
+}
+
+// enrichIncidents fetches weather for incidents using a bounded pool of workers and
+// streams the results back. The returned channel is closed once every dispatched
+// incident has been enriched; cancelling ctx stops dispatching new incidents and aborts
+// in-flight NWS requests, leaving those incidents without weather.
+func enrichIncidents(ctx context.Context, incidents []Incident, workers int) <-chan enrichedIncident {
+	jobs := make(chan Incident)
+	results := make(chan enrichedIncident)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for incident := range jobs {
+				results <- enrichIncident(ctx, incident)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, incident := range incidents {
+			select {
+			case jobs <- incident:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// validCoordinates reports whether lat/lon is a plausible point inside the continental
+// US bounding box that NWS covers. Null island (0,0) is rejected explicitly.
+func validCoordinates(lat, lon float64) bool {
+	if lat == 0 && lon == 0 {
+		return false
+	}
+	return lat >= 24 && lat <= 50 && lon >= -125 && lon <= -66
+}
+
+// enrichIncident fetches weather, and alerts if enabled, for a single incident, unless
+// weather is disabled. A panic during the lookup is
+// recovered and the incident is returned without weather so the batch keeps going.
+func enrichIncident(ctx context.Context, incident Incident) (result enrichedIncident) {
+	result.incident = incident
+	ctx = withCorrelationID(ctx, incident)
+	start := time.Now()
+	defer func() {
+		result.enrichDuration = time.Since(start)
+		if r := recover(); r != nil {
+			weatherFetchErrorsTotal.Inc()
+			slog.ErrorContext(ctx, "Recovered from panic during weather enrichment", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "panic", r)
+			result.weather, result.alerts = nil, nil
+			result.weatherErr = fmt.Errorf("panic during weather enrichment: %v", r)
+			result.weatherStatus = weatherStatusError
+		}
+	}()
+
+	if !enableWeather {
+		result.weatherStatus = weatherStatusDisabled
+		return result
+	}
+	// Points outside the NWS area, such as Alaska, Hawaii, or offshore, go straight to
+	// the fallback provider when there is one.
+	useFallback := !validCoordinates(incident.Lat, incident.Long) && fallbackCovers(incident.Lat, incident.Long)
+	if !validCoordinates(incident.Lat, incident.Long) && !useFallback {
+		slog.WarnContext(ctx, "Skipping weather for incident with invalid coordinates", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "lat", incident.Lat, "long", incident.Long)
+		runDebug.NoWeather(incident, "invalid_coordinates")
+		result.weatherStatus = weatherStatusNoCoverage
+		return result
+	}
+
+	// Historical sources need the incident's own time; an unparseable timestamp falls
+	// back to now, as the stored timestamp does.
+	at, terr := parseIncidentTime(incident.Timestamp, incidentLocation)
+	if terr != nil {
+		at = time.Now()
+	}
+	weatherCtx, span := tracer.Start(ctx, "weather.lookup", incidentAttributes(incident))
+	weatherStart := time.Now()
+	var weatherData *WeatherData
+	var err error
+	if useFallback {
+		slog.DebugContext(ctx, "Incident is outside the NWS area, using the fallback provider", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "lat", incident.Lat, "long", incident.Long)
+		weatherData, err = getFallbackWeather(weatherCtx, incident.Lat, incident.Long)
+	} else {
+		weatherData, err = weatherSource.Weather(weatherCtx, incident.Lat, incident.Long, at)
+	}
+	result.weatherDuration = time.Since(weatherStart)
+	endSpan(span, err)
+	switch {
+	case err == nil:
+		result.weatherStatus = weatherStatusOK
+	case errors.Is(err, ErrOutsideCoverage):
+		result.weatherStatus = weatherStatusNoCoverage
+		slog.DebugContext(ctx, "Incident is outside NWS coverage, no weather", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "lat", incident.Lat, "long", incident.Long)
+		runDebug.NoWeather(incident, "outside_coverage")
+	case errors.Is(err, ErrNWSUnavailable):
+		result.weatherStatus = weatherStatusError
+		weatherFetchErrorsTotal.Inc()
+		slog.ErrorContext(ctx, "NWS unavailable, could not fetch weather for incident", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "jurisdiction", incident.Jurisdiction, "error", err)
+	default:
+		result.weatherStatus = weatherStatusError
+		weatherFetchErrorsTotal.Inc()
+		slog.WarnContext(ctx, "Could not fetch weather for incident", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "jurisdiction", incident.Jurisdiction, "error", err)
+	}
+	result.weather, result.weatherErr = weatherData, err
+
+	if enableNWSAlerts && !useFallback {
+		alerts, err := getActiveAlertsForIncident(ctx, incident.Lat, incident.Long)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not fetch NWS alerts for incident", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "error", err)
+		}
+		result.alerts = alerts
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// forecastPoint is what the NWS points endpoint resolved a coordinate to: its
+// forecastHourly URL and the grid cell that URL serves.
+type forecastPoint struct {
+	URL  string
+	Grid NWSGrid
+}
+
+// forecastCacheEntry is a resolved point and when it stops being trusted.
+type forecastCacheEntry struct {
+	point     forecastPoint
+	expiresAt time.Time
+}
+
+// forecastURLCache maps rounded coordinates to the forecastPoint returned by the points
+// endpoint, so nearby incidents don't repeat the points lookup. Safe for concurrent use.
+type forecastURLCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]forecastCacheEntry
+}
+
+func newForecastURLCache(ttl time.Duration) *forecastURLCache {
+	return &forecastURLCache{ttl: ttl, entries: make(map[string]forecastCacheEntry)}
+}
+
+// Get returns the cached point for key, if present and not expired.
+func (c *forecastURLCache) Get(key string) (forecastPoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		weatherCacheMissesTotal.Inc()
+		return forecastPoint{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		weatherCacheMissesTotal.Inc()
+		return forecastPoint{}, false
+	}
+	weatherCacheHitsTotal.Inc()
+	return entry.point, true
+}
+
+// Set stores point under key for the cache's TTL.
+func (c *forecastURLCache) Set(key string, point forecastPoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = forecastCacheEntry{point: point, expiresAt: time.Now().Add(c.ttl)}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// geocoderURL is the Nominatim-compatible reverse geocoding endpoint. Overridden by GEOCODER_URL.
+var geocoderURL = "https://nominatim.openstreetmap.org/reverse"
+
+var (
+	geocodeClient = &http.Client{Timeout: 10 * time.Second, Transport: outboundTransport}
+
+	// geocodeLimiter holds lookups to one per second, the most Nominatim's usage
+	// policy allows. Cache hits don't consume it.
+	geocodeLimiter = rate.NewLimiter(1, 1)
+
+	geocodeMu    sync.Mutex
+	geocodeCache = make(map[string]string)
+)
+
+// nominatimResponse holds the fields we use from a Nominatim reverse lookup.
+type nominatimResponse struct {
+	DisplayName string `json:"display_name"`
+	Error       string `json:"error"`
+}
+
+// needsAddress reports whether an incident's address is missing and must be geocoded.
+func needsAddress(address string) bool {
+	address = strings.TrimSpace(address)
+	return address == "" || strings.EqualFold(address, "UNKNOWN")
+}
+
+// geocodeKey rounds a coordinate to four decimal places (about 11 m) for the geocoder
+// cache and the coordinate fallback address. It deliberately ignores COORD_PRECISION,
+// which is coarse enough at low settings to reuse a neighbor's address.
+func geocodeKey(lat, lon float64) string {
+	return fmt.Sprintf("%.4f,%.4f", lat, lon)
+}
+
+// fillMissingAddress reverse-geocodes the incident's coordinates into an address. If the
+// lookup fails the coordinate string is used instead. Either way the incident's
+// source_id is built from geocodeKey rather than the address, so it doesn't change when
+// a failed lookup later succeeds or Nominatim's answer changes. Successful results are
+// cached by geocodeKey; failures are retried on the next poll.
+func fillMissingAddress(ctx context.Context, incident *Incident) {
+	key := geocodeKey(incident.Lat, incident.Long)
+	incident.geocodedFrom = key
+
+	geocodeMu.Lock()
+	address, ok := geocodeCache[key]
+	geocodeMu.Unlock()
+	if ok {
+		incident.Address = address
+		return
+	}
+
+	address, err := reverseGeocode(ctx, incident.Lat, incident.Long)
+	if err != nil {
+		slog.Warn("Could not reverse-geocode incident, using coordinates as address", "coordinates", key, "jurisdiction", incident.Jurisdiction, "error", err)
+		incident.Address = key
+		return
+	}
+
+	geocodeMu.Lock()
+	geocodeCache[key] = address
+	geocodeMu.Unlock()
+	incident.Address = address
+}
+
+// reverseGeocode looks up the display address for a coordinate.
+func reverseGeocode(ctx context.Context, lat, lon float64) (string, error) {
+	if !validCoordinates(lat, lon) {
+		return "", fmt.Errorf("invalid coordinates")
+	}
+	if err := geocodeLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"format": {"jsonv2"},
+		"lat":    {fmt.Sprintf("%.4f", lat)},
+		"lon":    {fmt.Sprintf("%.4f", lon)},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", geocoderURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", rweccUserAgent)
+
+	resp, err := geocodeClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch reverse geocode: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("geocoder returned non-200 status: %s", resp.Status)
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read geocoder response body: %w", err)
+	}
+	var result nominatimResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal geocoder JSON: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("geocoder error: %s", result.Error)
+	}
+	if result.DisplayName == "" {
+		return "", fmt.Errorf("geocoder response did not contain an address")
+	}
+	return result.DisplayName, nil
+}
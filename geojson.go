@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// geojsonOut is the file the feeds' current incidents are exported to. Set by GEOJSON_OUT.
+var geojsonOut string
+
+// geojsonFeeds holds each feed's incidents from its last fetched payload, keyed by
+// source_id, with the weather from when each was last enriched. Unchanged incidents
+// aren't re-enriched, so their weather is carried over from earlier runs; after a
+// restart it is missing until they change. Runs are sequential, so it needs no locking.
+var geojsonFeeds = make(map[string]map[string]enrichedIncident)
+
+// geojsonStale reports that geojsonFeeds changed since the export was last written.
+var geojsonStale bool
+
+// updateGeoJSONFeed replaces source's exported incidents with matched, this payload's
+// incidents, taking weather from processed where they were enriched this run.
+func updateGeoJSONFeed(source string, matched []Incident, processed []enrichedIncident) {
+	enriched := make(map[string]enrichedIncident, len(processed))
+	for _, row := range processed {
+		enriched[sourceIDFor(row.incident)] = row
+	}
+	prev := geojsonFeeds[source]
+	rows := make(map[string]enrichedIncident, len(matched))
+	for _, incident := range matched {
+		id := sourceIDFor(incident)
+		row, ok := enriched[id]
+		if !ok {
+			row = prev[id]
+			row.incident = incident
+		}
+		rows[id] = row
+	}
+	geojsonFeeds[source] = rows
+	geojsonStale = true
+}
+
+// geojsonRows returns every feed's exported incidents, ordered by source and source_id
+// so the file only changes when the incidents do.
+func geojsonRows() []enrichedIncident {
+	sources := make([]string, 0, len(geojsonFeeds))
+	for source := range geojsonFeeds {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	var rows []enrichedIncident
+	for _, source := range sources {
+		ids := make([]string, 0, len(geojsonFeeds[source]))
+		for id := range geojsonFeeds[source] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			rows = append(rows, geojsonFeeds[source][id])
+		}
+	}
+	return rows
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONPoint      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Address      string       `json:"address"`
+	Problem      string       `json:"problem"`
+	Jurisdiction string       `json:"jurisdiction"`
+	Timestamp    string       `json:"timestamp"`
+	Weather      *WeatherData `json:"weather"`
+}
+
+// writeGeoJSON exports incidents as a FeatureCollection of points. The file is written
+// to a temporary sibling and renamed into place so readers never see a partial file.
+func writeGeoJSON(path string, rows []enrichedIncident) error {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(rows))}
+	for _, row := range rows {
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type: "Feature",
+			// GeoJSON positions are [longitude, latitude].
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]float64{row.incident.Long, row.incident.Lat}},
+			Properties: geoJSONProperties{
+				Address:      row.incident.Address,
+				Problem:      row.incident.Problem,
+				Jurisdiction: row.incident.Jurisdiction,
+				Timestamp:    row.incident.Timestamp,
+				Weather:      row.weather,
+			},
+		})
+	}
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return fmt.Errorf("marshalling GeoJSON: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temp GeoJSON file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing GeoJSON: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp GeoJSON file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("renaming GeoJSON into place: %w", err)
+	}
+	return nil
+}
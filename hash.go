@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// contentHash fingerprints the raw incident as received from the feed, excluding any
+// enrichment, so an unchanged incident can be recognized on later polls.
+func contentHash(incident Incident) string {
+	data, _ := json.Marshal(incident)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadContentHashes returns the stored content_hash of each row among sourceIDs, whatever
+// its status, so incidents saved as resolved or cancelled aren't rewritten on every poll
+// while they stay in the feed. Rows whose weather lookup failed are left out, so they
+// count as changed and the lookup is retried on the next poll. So are rows resolved
+// because they went missing (missed_runs > 0): one back in the feed is rewritten, which
+// restores its status and clears missed_runs.
+func loadContentHashes(ctx context.Context, db *sql.DB, source string, sourceIDs []string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT source_id, content_hash FROM unified_incidents
+		WHERE source = $1 AND content_hash IS NOT NULL AND source_id = ANY($2)
+			AND weather_status IS DISTINCT FROM $3
+			AND NOT (status = $4 AND missed_runs > 0)`,
+		source, pq.Array(sourceIDs), weatherStatusError, statusResolved)
+	if err != nil {
+		return nil, fmt.Errorf("loading content hashes: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string, len(sourceIDs))
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, fmt.Errorf("scanning content hash: %w", err)
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadStoredSourceIDs returns which of sourceIDs already have a row.
+func loadStoredSourceIDs(ctx context.Context, db *sql.DB, source string, sourceIDs []string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT source_id FROM unified_incidents WHERE source = $1 AND source_id = ANY($2)`,
+		source, pq.Array(sourceIDs))
+	if err != nil {
+		return nil, fmt.Errorf("loading stored source IDs: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]bool, len(sourceIDs))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning source ID: %w", err)
+		}
+		stored[id] = true
+	}
+	return stored, rows.Err()
+}
+
+// skipUnchanged drops incidents whose content hash matches the stored row, so they are
+// neither re-enriched nor rewritten. With CONFLICT_MODE=skip a stored row is never
+// rewritten, so every incident already stored is dropped whatever its hash. It returns
+// the incidents that still need processing.
+func skipUnchanged(ctx context.Context, db *sql.DB, source string, incidents []Incident) ([]Incident, int, error) {
+	ids := make([]string, len(incidents))
+	for i, incident := range incidents {
+		ids[i] = sourceIDFor(incident)
+	}
+	var unchanged func(i int) bool
+	if conflictMode == conflictSkip {
+		stored, err := loadStoredSourceIDs(ctx, db, source, ids)
+		if err != nil {
+			return incidents, 0, err
+		}
+		unchanged = func(i int) bool { return stored[ids[i]] }
+	} else {
+		stored, err := loadContentHashes(ctx, db, source, ids)
+		if err != nil {
+			return incidents, 0, err
+		}
+		unchanged = func(i int) bool {
+			hash, ok := stored[ids[i]]
+			return ok && hash == contentHash(incidents[i])
+		}
+	}
+	changed := incidents[:0:0]
+	for i, incident := range incidents {
+		if !unchanged(i) {
+			changed = append(changed, incident)
+		}
+	}
+	return changed, len(incidents) - len(changed), nil
+}
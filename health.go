@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runHealth tracks the outcome of the most recent ingestion run for the /healthz probe.
+type runHealth struct {
+	mu           sync.Mutex
+	lastRunTime  time.Time
+	lastRunError error
+}
+
+// health is the process-wide run tracker updated after every runOnce.
+var health runHealth
+
+// Record stores the result of a completed run.
+func (h *runHealth) Record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRunTime = time.Now()
+	h.lastRunError = err
+}
+
+// Check returns an error if the last run failed or is older than staleAfter. A zero
+// staleAfter disables the staleness check. Before the first run completes it reports
+// healthy: the server only starts once the database is reachable, and a long first run,
+// such as a backfill, shouldn't get the process restarted.
+func (h *runHealth) Check(staleAfter time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastRunTime.IsZero() {
+		return nil
+	}
+	if h.lastRunError != nil {
+		return fmt.Errorf("last run failed: %w", h.lastRunError)
+	}
+	if staleAfter > 0 && time.Since(h.lastRunTime) > staleAfter {
+		return fmt.Errorf("last successful run was %s ago", time.Since(h.lastRunTime).Round(time.Second))
+	}
+	return nil
+}
+
+// startHealthServer serves /healthz, reflecting the last completed run, and /readyz,
+// which pings the database, on addr in the background.
+func startHealthServer(addr string, db *sql.DB, staleAfter time.Duration) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := health.Check(staleAfter); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			http.Error(w, fmt.Sprintf("database ping failed: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	go func() {
+		slog.Info("Serving health checks", "addr", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("Health server stopped", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		lastRun time.Duration // how long ago the last run completed; zero means none has
+		lastErr error
+		wantErr string
+	}{
+		{name: "first run still going"},
+		{name: "fresh run", lastRun: time.Minute},
+		{name: "failed run", lastRun: time.Minute, lastErr: errors.New("feed down"), wantErr: "last run failed"},
+		{name: "stale run", lastRun: time.Hour, wantErr: "last successful run was"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h runHealth
+			if tt.lastRun > 0 {
+				h.lastRunTime, h.lastRunError = time.Now().Add(-tt.lastRun), tt.lastErr
+			}
+			err := h.Check(10 * time.Minute)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check = %v, want healthy", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
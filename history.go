@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// incidentHistory enables recording an incident's previous details whenever the feed
+// changes it. Set by INCIDENT_HISTORY.
+var incidentHistory bool
+
+// createIncidentHistorySQL creates the table of superseded incident versions.
+const createIncidentHistorySQL = `
+	CREATE TABLE IF NOT EXISTS incident_history (
+		id                BIGSERIAL PRIMARY KEY,
+		source            TEXT NOT NULL,
+		source_id         TEXT NOT NULL,
+		changed_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+		previous_details  JSONB,
+		new_raw_incident  JSONB NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS incident_history_source_id_idx ON incident_history (source, source_id);
+`
+
+// recordHistorySQL copies the stored row's details into incident_history when the incoming
+// raw incident ($3), whose content hash is $4, differs from the stored one. The hashes
+// are compared rather than details->'raw_incident', which DETAILS_MAX_BYTES may have
+// dropped. Rows stored before content_hash existed fall back to the raw incident, when
+// they have one. New incidents match nothing.
+const recordHistorySQL = `
+	INSERT INTO incident_history (source, source_id, previous_details, new_raw_incident)
+	SELECT source, source_id, details, $3::jsonb FROM unified_incidents
+	WHERE source = $1 AND source_id = $2 AND (
+		content_hash <> $4
+		OR (content_hash IS NULL AND details->'raw_incident' IS NOT NULL
+			AND details->'raw_incident' <> $3::jsonb)
+	);
+`
+
+func ensureIncidentHistoryTable(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, createIncidentHistorySQL); err != nil {
+		return fmt.Errorf("creating incident_history table: %w", err)
+	}
+	return nil
+}
+
+// recordIncidentHistory saves the previous version of incident if the feed changed it. It
+// must run before the upsert overwrites the stored details.
+func recordIncidentHistory(ctx context.Context, stmt *sql.Stmt, incident Incident) error {
+	raw, err := json.Marshal(incident)
+	if err != nil {
+		return fmt.Errorf("marshalling incident for history: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, incidentSource(incident), sourceIDFor(incident), string(raw), contentHash(incident)); err != nil {
+		return fmt.Errorf("recording incident history: %w", err)
+	}
+	return nil
+}
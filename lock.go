@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// advisoryLockID is the Postgres advisory lock key held for the duration of each run, so
+// replicas sharing a database don't ingest concurrently. Zero disables locking.
+// Overridden by ADVISORY_LOCK_ID.
+var advisoryLockID int64
+
+// acquireRunLock tries to take the run lock without waiting. Advisory locks belong to a
+// session, so the lock is held on a dedicated connection that release unlocks and returns
+// to the pool. ok is false when another instance holds the lock.
+func acquireRunLock(ctx context.Context, db *sql.DB) (release func(), ok bool, err error) {
+	if advisoryLockID == 0 {
+		return func() {}, true, nil
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("reserving connection for advisory lock: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", advisoryLockID).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("acquiring advisory lock: %w", err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+	release = func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			slog.Error("Error releasing advisory lock", "lock_id", advisoryLockID, "error", err)
+		}
+		conn.Close()
+	}
+	return release, true, nil
+}
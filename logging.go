@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// levelFatal is the level fatal logs at. It is above every level LOG_LEVEL accepts, so
+// the reason for an exit is always printed.
+const levelFatal = slog.LevelError + 4
+
+// parseLogLevel parses LOG_LEVEL: debug, info, warn, or error. Empty means info.
+func parseLogLevel(v string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (want debug, info, warn, or error)", v)
+}
+
+// setupLogger installs the default slog logger, dropping records below level. LOG_FORMAT
+// selects "json" (the default, for the log aggregator) or "text" for human-readable
+// local output.
+func setupLogger(format string, level slog.Level) {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == levelFatal {
+				a.Value = slog.StringValue("FATAL")
+			}
+			return a
+		},
+	}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(correlationHandler{handler}))
+}
+
+// correlationKey is the context key holding an incident's correlation ID.
+type correlationKey struct{}
+
+// correlationID is a short, stable ID for an incident, derived from its source and
+// source_id, so one incident's fetch, enrichment, and save can be grepped out of
+// interleaved logs.
+func correlationID(incident Incident) string {
+	sum := sha256.Sum256([]byte(incidentSource(incident) + "\x00" + sourceIDFor(incident)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// withCorrelationID tags ctx with incident's correlation ID. Records logged with the
+// *Context slog functions under ctx, including those deep in the NWS client, carry it.
+func withCorrelationID(ctx context.Context, incident Incident) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID(incident))
+}
+
+// correlationHandler adds a correlation_id attribute to records whose context has one.
+type correlationHandler struct {
+	slog.Handler
+}
+
+func (h correlationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(correlationKey{}).(string); ok {
+		r.AddAttrs(slog.String("correlation_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return correlationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h correlationHandler) WithGroup(name string) slog.Handler {
+	return correlationHandler{h.Handler.WithGroup(name)}
+}
+
+// fatal logs msg at levelFatal and exits, replacing the standard library's log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Log(context.Background(), levelFatal, msg, args...)
+	os.Exit(1)
+}
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	// Embedded so INCIDENT_TIMEZONE resolves even in minimal containers without zoneinfo.
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 )
 
 // Incident struct matches the JSON object structure from the API.
@@ -23,204 +38,1738 @@ type Incident struct {
 	Lat          float64 `json:"lat"`
 	Long         float64 `json:"long"`
 	Timestamp    string  `json:"timestamp"`
+	// Status is the feed's own state for the incident, when it provides one; see
+	// incidentStatus. It is omitted when empty so older payloads hash as before.
+	Status string `json:"status,omitempty"`
+
+	// Source is the label of the feed the incident came from; it isn't part of the payload.
+	Source string `json:"-"`
+	// geocodedFrom is the geocodeKey of an incident whose address was filled in by
+	// fillMissingAddress; sourceIDFor uses it in place of the address.
+	geocodedFrom string
 }
 
 // --- Structs for the National Weather Service (NWS) API ---
 type NWSPointsResponse struct {
 	Properties struct {
 		ForecastHourly string `json:"forecastHourly"`
+		NWSGrid
 	} `json:"properties"`
 }
 
+// NWSGrid identifies the forecast office and grid cell the NWS uses for a point.
+type NWSGrid struct {
+	GridID string `json:"gridId"`
+	GridX  int    `json:"gridX"`
+	GridY  int    `json:"gridY"`
+}
+
 type NWSHourlyResponse struct {
 	Properties struct {
 		Periods []WeatherData `json:"periods"`
 	} `json:"properties"`
 }
 
-// WeatherData holds the current weather conditions from the NWS.
+// WeatherData holds the current weather conditions from the NWS, or from the fallback
+// provider mapped into the same shape.
 type WeatherData struct {
-	Temperature   int    `json:"temperature"`
-	WindSpeed     string `json:"windSpeed"`
+	Temperature int `json:"temperature"`
+	// TemperatureUnit is "F" or "C", following the units requested from NWS.
+	TemperatureUnit string `json:"temperatureUnit"`
+	WindSpeed       string `json:"windSpeed"`
+	// WindDirection is a compass point such as "NW"; NWS leaves it empty when calm.
+	WindDirection string `json:"windDirection"`
 	ShortForecast string `json:"shortForecast"`
 	Icon          string `json:"icon"`
+	// StartTime and EndTime bound the forecast period the conditions apply to.
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// Grid is the NWS grid cell the forecast came from, when known. It is stored under
+	// its own details key rather than inside the weather object.
+	Grid *NWSGrid `json:"-"`
+	// Provider names the service the conditions came from, stored as
+	// details.weather_provider. Historical sources leave it empty.
+	Provider string `json:"-"`
+}
+
+// nwsBaseURL is the root of the NWS API. Overridden by NWS_BASE_URL to route through a
+// caching proxy or at a fake server.
+var nwsBaseURL = "https://api.weather.gov"
+
+// nwsURL joins path onto nwsBaseURL, tolerating a trailing slash on the base.
+func nwsURL(path string) string {
+	return strings.TrimRight(nwsBaseURL, "/") + "/" + strings.TrimLeft(path, "/")
 }
 
-// getWeatherForIncident fetches current weather conditions from the NWS API.
-func getWeatherForIncident(lat, lon float64) (*WeatherData, error) {
-	pointsURL := fmt.Sprintf("https://api.weather.gov/points/%.4f,%.4f", lat, lon)
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", pointsURL, nil)
+// defaultNWSUserAgent names the app but no operator, so deployments must replace it.
+const defaultNWSUserAgent = "rwecc-ingestor-bot (https://github.com/mtickle/rwecc-ingestor-bot)"
+
+// nwsUserAgent identifies this deployment to the NWS API, which requires contact info.
+// Overridden by NWS_USER_AGENT.
+var nwsUserAgent = defaultNWSUserAgent
+
+const (
+	// weatherBackoffBase is the delay before the first NWS retry; it doubles on each attempt.
+	weatherBackoffBase = 500 * time.Millisecond
+	// weatherDeadline bounds the total time spent on one incident's weather lookup, retries included.
+	weatherDeadline = 30 * time.Second
+)
+
+// tempMinF and tempMaxF bound a plausible temperature in °F. NWS occasionally returns
+// wild values during API glitches; anything outside is treated as missing weather.
+// Overridden by TEMP_MIN_F and TEMP_MAX_F.
+var tempMinF, tempMaxF float64 = -60, 140
+
+// errImplausibleTemperature reports a forecast whose temperature is outside the bounds.
+var errImplausibleTemperature = errors.New("implausible temperature")
+
+// checkTemperature rejects weather whose temperature, converted to °F, is outside
+// [tempMinF, tempMaxF].
+func checkTemperature(weather *WeatherData) error {
+	f := float64(weather.Temperature)
+	if weather.TemperatureUnit == "C" {
+		f = f*9/5 + 32
+	}
+	if f < tempMinF || f > tempMaxF {
+		return fmt.Errorf("%w: %d°%s", errImplausibleTemperature, weather.Temperature, weather.TemperatureUnit)
+	}
+	return nil
+}
+
+// weatherUnits is the NWS unit system, "us" (Fahrenheit, mph) or "si" (Celsius, km/h).
+// Overridden by WEATHER_UNITS.
+var weatherUnits = "us"
+
+// weatherMaxRetries is how many times a failed NWS request is retried. Overridden by WEATHER_MAX_RETRIES.
+var weatherMaxRetries = 3
+
+// forecastCache remembers points-to-forecast lookups across incidents. Its TTL is set by WEATHER_CACHE_TTL.
+var forecastCache = newForecastURLCache(24 * time.Hour)
+
+// coordPrecision is how many decimal places coordinates are rounded to for NWS points
+// lookups, the forecast URL cache, and in-flight lookup sharing. Fewer places means more
+// incidents share a cache entry and a lookup, at the cost of using weather for a point
+// up to roughly 1.1 km (2 places) or 110 m (3 places) away rather than 11 m (4 places).
+// NWS doesn't accept more than 4. Overridden by COORD_PRECISION.
+var coordPrecision = 4
+
+// coordKey rounds a coordinate pair to coordPrecision decimal places.
+func coordKey(lat, lon float64) string {
+	return fmt.Sprintf("%.*f,%.*f", coordPrecision, lat, coordPrecision, lon)
+}
+
+// nwsClient is shared by all NWS requests. NWS rejects requests without a User-Agent,
+// so it is re-applied on redirects (e.g. points moving to another grid office).
+var nwsClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: outboundTransport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		req.Header.Set("User-Agent", nwsUserAgent)
+		return nil
+	},
+}
+
+// nwsLimiter is a token bucket shared by every NWS request, including retries, so the
+// request rate stays bounded however many weather workers run. Replaced from
+// NWS_RATE_LIMIT (requests per second) at startup.
+var nwsLimiter = rate.NewLimiter(2, 1)
+
+// nwsMaxRetryAfter caps how long we honor an NWS Retry-After header.
+const nwsMaxRetryAfter = 20 * time.Second
+
+// Weather lookups wrap one of these so callers can tell an expected coverage miss from an
+// NWS outage with errors.Is.
+var (
+	// ErrOutsideCoverage means NWS has no forecast for the point (a 404 from the API).
+	ErrOutsideCoverage = errors.New("point is outside NWS coverage")
+	// ErrNWSUnavailable means NWS couldn't be reached or answered with a 429 or 5xx.
+	ErrNWSUnavailable = errors.New("NWS is unavailable")
+)
+
+// maxResponseBytes bounds how much of any upstream response body is read, so a broken or
+// hostile server can't exhaust memory. Overridden by MAX_RESPONSE_BYTES.
+var maxResponseBytes int64 = 32 << 20
+
+// errResponseTooLarge means a response body exceeded maxResponseBytes.
+var errResponseTooLarge = errors.New("response body exceeds MAX_RESPONSE_BYTES")
+
+// readLimited reads r to EOF, failing with errResponseTooLarge past maxResponseBytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxResponseBytes+1))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "(patrolx, mtickle@gmail.com)")
+	if int64(len(body)) > maxResponseBytes {
+		return nil, fmt.Errorf("%w (%d bytes)", errResponseTooLarge, maxResponseBytes)
+	}
+	return body, nil
+}
+
+// limitedBody is readLimited for a streaming decoder: it passes through at most
+// maxResponseBytes of r and fails with errResponseTooLarge if there is more. err records
+// the first read failure, so a caller can tell a transport error from malformed JSON.
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func newLimitedBody(r io.Reader) *limitedBody {
+	return &limitedBody{r: r, remaining: maxResponseBytes}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.remaining <= 0 {
+		// At the limit, a single further byte means the body is too large.
+		var probe [1]byte
+		_, err := io.ReadFull(b.r, probe[:])
+		switch {
+		case err == io.EOF:
+			return 0, io.EOF
+		case err == nil:
+			err = fmt.Errorf("%w (%d bytes)", errResponseTooLarge, maxResponseBytes)
+		}
+		b.err = err
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
 
-	pointsResp, err := client.Do(req)
+// fetchNWS performs a GET against the NWS API, retrying network errors, 429s, and 5xx
+// responses with exponential backoff until weatherMaxRetries or ctx's deadline is exhausted.
+// A 429's Retry-After is honored, up to nwsMaxRetryAfter. A 404 is returned immediately
+// since it means the point is outside NWS coverage.
+func fetchNWS(ctx context.Context, client *http.Client, url, label string) ([]byte, error) {
+	policy := retryPolicy{
+		name:          "NWS " + label,
+		maxRetries:    weatherMaxRetries,
+		backoff:       weatherBackoffBase,
+		maxRetryAfter: nwsMaxRetryAfter,
+	}
+	var body []byte
+	err := withRetry(ctx, policy, func() error {
+		var err error
+		body, err = fetchNWSOnce(ctx, client, url, label)
+		return err
+	})
+	return body, err
+}
+
+// fetchNWSOnce performs a single NWS GET. Failures worth retrying are returned as a
+// *retryableError wrapping ErrNWSUnavailable; a 404 wraps ErrOutsideCoverage.
+func fetchNWSOnce(ctx context.Context, client *http.Client, url, label string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch NWS points data: %w", err)
+		return nil, err
 	}
-	defer pointsResp.Body.Close()
-	if pointsResp.StatusCode != 200 {
-		return nil, fmt.Errorf("NWS points API returned non-200 status: %s", pointsResp.Status)
+	req.Header.Set("User-Agent", nwsUserAgent)
+	if err := nwsLimiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for NWS rate limiter: %w", err)
 	}
-	body, err := io.ReadAll(pointsResp.Body)
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read NWS points response body: %w", err)
+		return nil, &retryableError{err: fmt.Errorf("%w: failed to fetch NWS %s data: %w", ErrNWSUnavailable, label, err)}
 	}
-	var pointsResponse NWSPointsResponse
-	if err := json.Unmarshal(body, &pointsResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal NWS points JSON: %w", err)
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		err := fmt.Errorf("NWS %s API returned non-200 status: %s", label, resp.Status)
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("%w: %w", ErrOutsideCoverage, err)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return nil, &retryableError{err: fmt.Errorf("%w: %w", ErrNWSUnavailable, err), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		case resp.StatusCode >= 500:
+			return nil, &retryableError{err: fmt.Errorf("%w: %w", ErrNWSUnavailable, err)}
+		}
+		return nil, err
 	}
-	if pointsResponse.Properties.ForecastHourly == "" {
-		return nil, fmt.Errorf("NWS points response did not contain a forecast URL")
+	body, err := readLimited(resp.Body)
+	if errors.Is(err, errResponseTooLarge) {
+		return nil, fmt.Errorf("NWS %s response: %w", label, err)
 	}
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("%w: failed to read NWS %s response body: %w", ErrNWSUnavailable, label, err)}
+	}
+	return body, nil
+}
+
+// weatherFlight collapses concurrent lookups for the same rounded coordinates into one
+// NWS round trip.
+var weatherFlight singleflight.Group
 
-	req, err = http.NewRequest("GET", pointsResponse.Properties.ForecastHourly+"?units=us", nil)
+// getWeatherForIncident fetches current weather conditions from the NWS API. Concurrent
+// calls for the same coordKey share one in-flight lookup, and so share its ctx: if the
+// caller that started it is cancelled, the others see that error too. Points outside NWS
+// coverage are looked up with fallbackWeather, if one is configured. Errors wrap
+// ErrOutsideCoverage or ErrNWSUnavailable where the cause is known.
+func getWeatherForIncident(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	key := coordKey(lat, lon)
+	// A shared lookup runs under the first caller's context, so its NWS retry logs carry
+	// that incident's correlation ID.
+	v, err, shared := weatherFlight.Do(key, func() (any, error) {
+		return fetchWeatherForKey(ctx, key)
+	})
+	if shared {
+		singleflightSharedTotal.Inc()
+	}
+	if errors.Is(err, ErrOutsideCoverage) && fallbackWeather != nil {
+		slog.DebugContext(ctx, "Point is outside NWS coverage, trying the fallback provider", "lat", lat, "long", lon)
+		fallback, ferr := getFallbackWeather(ctx, lat, lon)
+		if ferr != nil {
+			return nil, fmt.Errorf("%w (fallback provider: %v)", err, ferr)
+		}
+		return fallback, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "(patrolx, mtickle@gmail.com)")
-	hourlyResp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch NWS hourly data: %w", err)
+	// Each caller gets its own copy, since the result is shared.
+	weather := *v.(*WeatherData)
+	weather.Provider = providerNWS
+	if err := checkTemperature(&weather); err != nil {
+		return nil, err
 	}
-	defer hourlyResp.Body.Close()
-	if hourlyResp.StatusCode != 200 {
-		return nil, fmt.Errorf("NWS hourly API returned non-200 status: %s", hourlyResp.Status)
+	return &weather, nil
+}
+
+// fetchWeatherForKey resolves key's forecast URL, from forecastCache when possible, and
+// returns the current hourly period.
+func fetchWeatherForKey(ctx context.Context, key string) (*WeatherData, error) {
+	ctx, cancel := context.WithTimeout(ctx, weatherDeadline)
+	defer cancel()
+
+	point, ok := forecastCache.Get(key)
+	if !ok {
+		body, err := fetchNWS(ctx, nwsClient, nwsURL("points/"+key), "points")
+		if err != nil {
+			return nil, err
+		}
+		var pointsResponse NWSPointsResponse
+		if err := json.Unmarshal(body, &pointsResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal NWS points JSON: %w", err)
+		}
+		if pointsResponse.Properties.ForecastHourly == "" {
+			return nil, fmt.Errorf("NWS points response did not contain a forecast URL")
+		}
+		point = forecastPoint{URL: pointsResponse.Properties.ForecastHourly, Grid: pointsResponse.Properties.NWSGrid}
+		forecastCache.Set(key, point)
 	}
-	hourlyBody, err := io.ReadAll(hourlyResp.Body)
+
+	hourlyBody, err := fetchNWS(ctx, nwsClient, point.URL+"?units="+weatherUnits, "hourly")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read NWS hourly response body: %w", err)
+		return nil, err
 	}
 	var hourlyResponse NWSHourlyResponse
 	if err := json.Unmarshal(hourlyBody, &hourlyResponse); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal NWS hourly JSON: %w", err)
 	}
 	if len(hourlyResponse.Properties.Periods) > 0 {
-		return &hourlyResponse.Properties.Periods[0], nil
+		weather := &hourlyResponse.Properties.Periods[0]
+		if point.Grid.GridID != "" {
+			grid := point.Grid
+			weather.Grid = &grid
+		}
+		return weather, nil
 	}
 	return nil, fmt.Errorf("no weather periods returned from NWS")
 }
 
-// saveToUnifiedDB normalizes and saves an incident to the unified table.
-func saveToUnifiedDB(db *sql.DB, incident Incident) error {
-	source := "RWECC"
-	sourceID := incident.Timestamp + " " + incident.Address
-	eventType := "Vehicle Crash"
+// defaultIncidentFilters is used when INCIDENT_FILTERS is unset, preserving the original MVC-only behavior.
+var defaultIncidentFilters = []string{"MVC"}
 
-	loc, _ := time.LoadLocation("America/New_York")
-	parsedTime, err := time.ParseInLocation("2006-01-02 15:04:05.000", incident.Timestamp, loc)
-	if err != nil {
-		log.Printf("Could not parse timestamp '%s', using current time. Error: %v", incident.Timestamp, err)
-		parsedTime = time.Now()
+// parseIncidentFilters splits a comma-separated INCIDENT_FILTERS value into upper-cased
+// keywords, compiling any /pattern/ entries, which are kept as written.
+func parseIncidentFilters(v string) ([]string, error) {
+	var filters []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if _, ok := regexPattern(f); !ok {
+			f = strings.ToUpper(f)
+		} else if err := compileProblemPattern(f); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 0 {
+		return defaultIncidentFilters, nil
+	}
+	return filters, nil
+}
+
+// matchesFilters reports whether problem matches any of the filters. Matching is a
+// case-insensitive substring test, so "FIRE" also matches "STRUCTURE FIRE", unless the
+// filter is a /pattern/.
+func matchesFilters(problem string, filters []string) bool {
+	problem = normalizeField(problem)
+	for _, f := range filters {
+		if matchesProblem(problem, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// incidentTimeLayouts are the timestamp formats seen in the RWECC feed, tried in order.
+var incidentTimeLayouts = []string{
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+// incidentLocation is the time zone RWECC timestamps are interpreted in, loaded once at
+// startup from INCIDENT_TIMEZONE.
+var incidentLocation *time.Location
+
+// parseIncidentTime parses an RWECC timestamp using the first matching layout. Layouts
+// without a zone are interpreted in loc.
+func parseIncidentTime(ts string, loc *time.Location) (time.Time, error) {
+	var firstErr error
+	for _, layout := range incidentTimeLayouts {
+		t, err := time.ParseInLocation(layout, ts, loc)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// jurisdictionAllow and jurisdictionDeny hold upper-cased jurisdiction names from
+// JURISDICTION_ALLOW and JURISDICTION_DENY. An empty set means the list is unset.
+var jurisdictionAllow, jurisdictionDeny map[string]bool
+
+// parseJurisdictionSet splits a comma-separated list of jurisdiction names into a set.
+func parseJurisdictionSet(v string) map[string]bool {
+	set := make(map[string]bool)
+	for _, j := range strings.Split(v, ",") {
+		if j = strings.TrimSpace(j); j != "" {
+			set[strings.ToUpper(j)] = true
+		}
+	}
+	return set
+}
+
+// jurisdictionAllowed applies the allow/deny lists with a case-insensitive exact match.
+// When an allow list is set it alone decides; the deny list is only consulted otherwise.
+func jurisdictionAllowed(jurisdiction string) bool {
+	j := strings.ToUpper(strings.TrimSpace(jurisdiction))
+	if len(jurisdictionAllow) > 0 {
+		return jurisdictionAllow[j]
+	}
+	return !jurisdictionDeny[j]
+}
+
+// addressBlocklist holds upper-cased substrings marking test or training records, such
+// as "TEST". Set by ADDRESS_BLOCKLIST; empty blocks nothing.
+var addressBlocklist []string
+
+// parseAddressBlocklist splits a comma-separated ADDRESS_BLOCKLIST into normalized
+// substrings.
+func parseAddressBlocklist(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = normalizeField(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// blocklisted reports whether the incident's address or problem contains any
+// addressBlocklist entry, ignoring case and spacing.
+func blocklisted(incident Incident) bool {
+	address, problem := normalizeField(incident.Address), normalizeField(incident.Problem)
+	for _, s := range addressBlocklist {
+		if strings.Contains(address, s) || strings.Contains(problem, s) {
+			return true
+		}
 	}
+	return false
+}
+
+// boundingBox is a rectangular area of interest in decimal degrees.
+type boundingBox struct {
+	MinLat, MinLong, MaxLat, MaxLong float64
+}
+
+// bbox, when set, skips incidents outside it before enrichment. Set by BBOX.
+var bbox *boundingBox
+
+// parseBoundingBox parses BBOX, "minLat,minLong,maxLat,maxLong". An empty value means no
+// box.
+func parseBoundingBox(v string) (*boundingBox, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("want minLat,minLong,maxLat,maxLong, got %q", v)
+	}
+	var vals [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q: %w", p, err)
+		}
+		vals[i] = f
+	}
+	box := &boundingBox{MinLat: vals[0], MinLong: vals[1], MaxLat: vals[2], MaxLong: vals[3]}
+	if box.MinLat >= box.MaxLat || box.MinLong >= box.MaxLong {
+		return nil, fmt.Errorf("minimums must be below maximums, got %q", v)
+	}
+	return box, nil
+}
 
-	// --- ENRICHMENT STEP ---
-	weatherData, err := getWeatherForIncident(incident.Lat, incident.Long)
+// contains reports whether the point lies inside the box, edges included.
+func (b *boundingBox) contains(lat, long float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && long >= b.MinLong && long <= b.MaxLong
+}
+
+// incidentSource is the source column value for an incident, defaulting to sourceName.
+func incidentSource(incident Incident) string {
+	if incident.Source == "" {
+		return sourceName
+	}
+	return incident.Source
+}
+
+// normalizeField collapses runs of whitespace, trims, and upper-cases a feed string so
+// that values differing only in spacing or case compare equal.
+func normalizeField(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), " "))
+}
+
+// sourceIDFor builds the unique per-source key for an incident from its normalized
+// timestamp and address, so the feed's inconsistent spacing doesn't split one incident
+// into several rows. The stored address column keeps the original text. Incidents that
+// arrived without an address are keyed on their geocoded coordinates instead.
+func sourceIDFor(incident Incident) string {
+	if incident.geocodedFrom != "" {
+		return normalizeField(incident.Timestamp) + " " + incident.geocodedFrom
+	}
+	return normalizeField(incident.Timestamp) + " " + normalizeField(incident.Address)
+}
+
+// upsertColumns are the unified_incidents columns written for each incident, in the
+// order buildUnifiedRow returns their values.
+var upsertColumns = []string{
+	"source", "source_id", "event_type", "address", "latitude", "longitude", "timestamp", "details",
+	"jurisdiction", "problem_detail", "weather_temp", "weather_wind_speed", "weather_forecast", "weather_icon",
+	"weather_observed_at", "content_hash", "severity", "weather_status", "status", "weather_wind_dir",
+}
+
+// closedFeedStatuses are feed status values, upper-cased, that mean the source has
+// closed the incident.
+var closedFeedStatuses = map[string]bool{
+	"CLOSED": true, "CLEARED": true, "RESOLVED": true, "COMPLETE": true, "COMPLETED": true,
+}
+
+// incidentStatus maps the feed's status to the status column: 'resolved' when the source
+// reports the incident closed, otherwise the first statusRules match on the problem,
+// otherwise 'active', including when the feed has no status.
+func incidentStatus(incident Incident) string {
+	if closedFeedStatuses[normalizeField(incident.Status)] {
+		return statusResolved
+	}
+	problem := normalizeField(incident.Problem)
+	for _, rule := range statusRules {
+		if matchesProblem(problem, rule.Match) {
+			return rule.Status
+		}
+	}
+	return statusActive
+}
+
+// Values of CONFLICT_MODE.
+const (
+	// conflictUpdate refreshes a re-seen incident's address, details, status, and weather.
+	conflictUpdate = "update"
+	// conflictSkip keeps the row as first stored, for append-only consumers.
+	conflictSkip = "skip"
+)
+
+// conflictMode decides what happens when an incident is already stored. Overridden by
+// CONFLICT_MODE.
+var conflictMode = conflictUpdate
+
+// upsertConflictSQL refreshes an existing incident's details, status, and weather, and its
+// address, which can change for incidents keyed on geocoded coordinates.
+const upsertConflictSQL = `
+	ON CONFLICT (source, source_id) DO UPDATE SET
+		address = EXCLUDED.address,
+		details = EXCLUDED.details,
+		status = EXCLUDED.status,
+		jurisdiction = EXCLUDED.jurisdiction,
+		problem_detail = EXCLUDED.problem_detail,
+		weather_temp = EXCLUDED.weather_temp,
+		weather_wind_speed = EXCLUDED.weather_wind_speed,
+		weather_wind_dir = EXCLUDED.weather_wind_dir,
+		weather_forecast = EXCLUDED.weather_forecast,
+		weather_icon = EXCLUDED.weather_icon,
+		weather_observed_at = EXCLUDED.weather_observed_at,
+		content_hash = EXCLUDED.content_hash,
+		severity = EXCLUDED.severity,
+		weather_status = EXCLUDED.weather_status,
+		missed_runs = 0`
+
+// skipConflictSQL leaves an existing incident untouched.
+const skipConflictSQL = `
+	ON CONFLICT (source, source_id) DO NOTHING`
+
+// conflictSQL returns the ON CONFLICT clause for conflictMode.
+func conflictSQL() string {
+	if conflictMode == conflictSkip {
+		return skipConflictSQL
+	}
+	return upsertConflictSQL
+}
+
+// insertSQL populates jurisdiction, problem_detail, and weather columns; upsertSQL adds
+// the ON CONFLICT clause.
+const insertSQL = `
+	INSERT INTO unified_incidents (
+		source, source_id, event_type, address, latitude, longitude, timestamp, details,
+		jurisdiction, problem_detail, weather_temp, weather_wind_speed, weather_forecast, weather_icon,
+		weather_observed_at, content_hash, severity, weather_status, status, weather_wind_dir
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
+
+// upsertSQL inserts an incident, handling an existing row according to conflictMode.
+func upsertSQL() string {
+	return insertSQL + conflictSQL() + `
+	RETURNING (xmax = 0) AS inserted;
+`
+}
+
+// detailsSchemaVersion identifies the shape of the details JSON so consumers can branch
+// on it. Bump it whenever keys are added, removed, or change meaning.
+//
+// Version 2 added nws_grid; version 3 added truncated; version 4 added
+// weather.windDirection; version 5 added weather_provider; version 6 added
+// weather_fetch_ms.
+const detailsSchemaVersion = 6
+
+// detailsMaxBytes caps the marshalled details JSON. Zero means no cap. Overridden by
+// DETAILS_MAX_BYTES.
+var detailsMaxBytes = 0
+
+// detailsDroppable are the optional details keys dropped, in order, to fit
+// detailsMaxBytes.
+var detailsDroppable = []string{"alerts", "raw_incident"}
+
+// marshalDetails marshals details, dropping the optional sections in detailsDroppable
+// until it fits within detailsMaxBytes so an oversized incident still saves its core row.
+// Each dropped key is listed under "truncated".
+func marshalDetails(details map[string]interface{}, sourceID string) ([]byte, error) {
+	detailsJSON, err := json.Marshal(details)
 	if err != nil {
-		log.Printf("Warning: could not fetch weather for incident '%s': %v", incident.Address, err)
+		return nil, fmt.Errorf("could not marshal unified details to JSON: %w", err)
+	}
+	var dropped []string
+	for _, key := range detailsDroppable {
+		if detailsMaxBytes == 0 || len(detailsJSON) <= detailsMaxBytes {
+			break
+		}
+		if _, ok := details[key]; !ok {
+			continue
+		}
+		delete(details, key)
+		dropped = append(dropped, key)
+		details["truncated"] = dropped
+		if detailsJSON, err = json.Marshal(details); err != nil {
+			return nil, fmt.Errorf("could not marshal unified details to JSON: %w", err)
+		}
+	}
+	if len(dropped) > 0 {
+		slog.Warn("Details exceeded DETAILS_MAX_BYTES, dropped optional sections", "source_id", sourceID, "dropped", dropped, "bytes", len(detailsJSON), "max_bytes", detailsMaxBytes)
+	}
+	return detailsJSON, nil
+}
+
+// buildUnifiedRow normalizes an incident and its already-fetched weather into the column
+// values for upsertColumns.
+func buildUnifiedRow(row enrichedIncident) ([]any, error) {
+	incident, weatherData, alerts := row.incident, row.weather, row.alerts
+	source := incidentSource(incident)
+	sourceID := sourceIDFor(incident)
+	eventType := classifyEventType(incident.Problem)
+	severity := classifySeverity(incident.Problem)
+
+	parsedTime, err := parseIncidentTime(incident.Timestamp, incidentLocation)
+	timestampFallback := err != nil
+	if timestampFallback {
+		slog.Warn("Could not parse timestamp, using current time", "timestamp", incident.Timestamp, "source_id", sourceID, "correlation_id", correlationID(incident), "error", err)
+		parsedTime = time.Now()
 	}
 
 	details := map[string]interface{}{
-		"raw_incident": incident,
-		"weather":      weatherData,
+		"schema_version": detailsSchemaVersion,
+		"raw_incident":   incident,
+		"weather":        weatherData,
+		"severity":       severity,
+	}
+	if timestampFallback {
+		details["timestamp_fallback"] = true
+	}
+	if alerts != nil {
+		details["alerts"] = alerts
+	}
+	if row.weatherDuration > 0 {
+		details["weather_fetch_ms"] = row.weatherDuration.Milliseconds()
+	}
+	if weatherData != nil {
+		details["weather_units"] = weatherUnits
+		if weatherData.Provider != "" {
+			details["weather_provider"] = weatherData.Provider
+		}
+		if weatherData.Grid != nil {
+			details["nws_grid"] = weatherData.Grid
+		}
 	}
 
-	detailsJSON, err := json.Marshal(details)
+	detailsJSON, err := marshalDetails(details, sourceID)
 	if err != nil {
-		return fmt.Errorf("could not marshal unified details to JSON: %w", err)
+		return nil, err
 	}
 
 	// --- PREPARE NEW COLUMN VALUES ---
 	var weatherTemp sql.NullInt32
-	var weatherWind, weatherForecast sql.NullString
+	var weatherWind, weatherWindDir, weatherForecast, weatherIcon sql.NullString
+	var weatherObservedAt sql.NullTime
 
 	if weatherData != nil {
 		weatherTemp.Int32 = int32(weatherData.Temperature)
 		weatherTemp.Valid = true
-		weatherWind.String = weatherData.WindSpeed
-		weatherWind.Valid = true
-		weatherForecast.String = weatherData.ShortForecast
-		weatherForecast.Valid = true
-	}
-
-	// Updated SQL to populate jurisdiction, problem_detail, and weather columns
-	sqlStatement := `
-		INSERT INTO unified_incidents (
-			source, source_id, event_type, status, address, latitude, longitude, timestamp, details,
-			jurisdiction, problem_detail, weather_temp, weather_wind_speed, weather_forecast
-		) VALUES ($1, $2, $3, 'active', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		ON CONFLICT (source, source_id) DO UPDATE SET
-			details = EXCLUDED.details,
-			status = 'active',
-			jurisdiction = EXCLUDED.jurisdiction,
-			problem_detail = EXCLUDED.problem_detail,
-			weather_temp = EXCLUDED.weather_temp,
-			weather_wind_speed = EXCLUDED.weather_wind_speed,
-			weather_forecast = EXCLUDED.weather_forecast;
-	`
-
-	_, err = db.Exec(sqlStatement,
+		// NWS sometimes returns a period with only some fields populated; store the
+		// empty ones as NULL rather than as empty strings.
+		weatherWind = nullIfEmpty(weatherData.WindSpeed)
+		weatherWindDir = nullIfEmpty(weatherData.WindDirection)
+		weatherForecast = nullIfEmpty(weatherData.ShortForecast)
+		weatherIcon = nullIfEmpty(weatherData.Icon)
+		weatherObservedAt.Time = weatherData.StartTime
+		weatherObservedAt.Valid = !weatherData.StartTime.IsZero()
+	}
+
+	return []any{
 		source, sourceID, eventType, incident.Address, incident.Lat, incident.Long, parsedTime, detailsJSON,
-		incident.Jurisdiction, incident.Problem, weatherTemp, weatherWind, weatherForecast,
-	)
+		incident.Jurisdiction, incident.Problem, weatherTemp, weatherWind, weatherForecast, weatherIcon,
+		weatherObservedAt, contentHash(incident), severity, row.weatherStatus, incidentStatus(incident), weatherWindDir,
+	}, nil
+}
+
+// nullIfEmpty is s as a nullable column value, NULL when s is blank.
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: strings.TrimSpace(s) != ""}
+}
+
+// logDryRunRow logs the column values a dry run would have written.
+func logDryRunRow(args []any) {
+	attrs := make([]any, 0, 2*len(args))
+	for i, col := range upsertColumns {
+		v := args[i]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		attrs = append(attrs, col, v)
+	}
+	slog.Info("Dry run: would upsert row", attrs...)
+}
+
+// saveToUnifiedDB normalizes an incident, with its already-fetched weather, and executes
+// the prepared upsertSQL statement for it. It reports whether the row was newly inserted
+// rather than an update of an existing incident, or one skipped under CONFLICT_MODE=skip.
+func saveToUnifiedDB(ctx context.Context, stmt *sql.Stmt, row enrichedIncident) (bool, error) {
+	args, err := buildUnifiedRow(row)
+	if err != nil {
+		return false, err
+	}
+	if dryRun {
+		logDryRunRow(args)
+		return false, nil
+	}
+
+	var inserted bool
+	err = stmt.QueryRowContext(ctx, args...).Scan(&inserted)
+	if errors.Is(err, sql.ErrNoRows) {
+		// DO NOTHING returns no row for an incident that was already stored.
+		return false, nil
+	}
+	return inserted, explainUpsertError(err)
+}
+
+// pingWithRetry pings the database, retrying up to retries times with a linearly
+// increasing delay so a briefly unavailable Postgres doesn't crash the bot at startup.
+func pingWithRetry(db *sql.DB, retries int) error {
+	var err error
+	for attempt := 1; attempt <= retries+1; attempt++ {
+		if err = db.Ping(); err == nil {
+			return nil
+		}
+		if attempt > retries {
+			break
+		}
+		delay := time.Duration(attempt) * 2 * time.Second
+		slog.Warn("Database connection failed, retrying", "attempt", attempt, "max_retries", retries, "retry_in", delay, "error", err)
+		time.Sleep(delay)
+	}
 	return err
 }
 
-func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Note: .env file not found")
+// dryRun skips all database writes, logging the rows that would have been upserted
+// instead. Set by the --dry-run flag or DRY_RUN=true.
+var dryRun bool
+
+// pollJitter spreads daemon polls by up to this fraction of the poll interval either way,
+// so several instances don't hit RWECC and NWS in lockstep. Overridden by POLL_JITTER.
+var pollJitter = 0.1
+
+// jitteredInterval returns interval shifted by a random amount within ±pollJitter of it.
+func jitteredInterval(interval time.Duration) time.Duration {
+	offset := (rand.Float64()*2 - 1) * pollJitter * float64(interval)
+	sleep := interval + time.Duration(offset)
+	slog.Debug("Sleeping until next poll", "sleep", sleep)
+	return sleep
+}
+
+// processLimit caps how many matching incidents a run processes; zero means no limit.
+// Set by the --limit flag or PROCESS_LIMIT.
+var processLimit int
+
+// rweccUserAgent identifies this bot to the RWECC feed.
+const rweccUserAgent = "rwecc-ingestor-bot (mtickle@gmail.com)"
+
+// rweccMaxRetries is how many times a failed feed page fetch is retried on a network
+// error, 429, or 5xx before the feed is given up for this run. Overridden by
+// RWECC_MAX_RETRIES.
+var rweccMaxRetries = 3
+
+const (
+	// rweccBackoffBase is the delay before the first feed retry; it doubles on each attempt.
+	rweccBackoffBase = time.Second
+	// rweccMaxRetryAfter caps how long we honor a feed's Retry-After header.
+	rweccMaxRetryAfter = 30 * time.Second
+)
+
+// rweccClient fetches the incident feed. Its timeout is set by RWECC_TIMEOUT.
+var rweccClient = &http.Client{Timeout: 30 * time.Second, Transport: outboundTransport}
+
+// feedAuth holds credentials for a feed behind authentication: a bearer token, or a
+// Basic username and password. The zero value sends no Authorization header.
+type feedAuth struct {
+	Bearer               string
+	BasicUser, BasicPass string
+}
+
+// rweccAuth is applied to every feed request. Set by RWECC_AUTH_BEARER, or by
+// RWECC_AUTH_BASIC_USER and RWECC_AUTH_BASIC_PASS.
+var rweccAuth feedAuth
+
+// apply sets req's Authorization header from a, if a has credentials.
+func (a feedAuth) apply(req *http.Request) {
+	switch {
+	case a.Bearer != "":
+		req.Header.Set("Authorization", "Bearer "+a.Bearer)
+	case a.BasicUser != "":
+		req.SetBasicAuth(a.BasicUser, a.BasicPass)
 	}
+}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
-		os.Getenv("DATABASE_HOST"), os.Getenv("DATABASE_PORT"), os.Getenv("DATABASE_USERNAME"),
-		os.Getenv("DATABASE_PASSWORD"), os.Getenv("DATABASE_NAME"))
+// incidentFilters holds the problem keywords an incident must match to be ingested.
+var incidentFilters = defaultIncidentFilters
 
-	db, err := sql.Open("postgres", psqlInfo)
-	if err != nil {
-		log.Fatalf("Error opening database: %s", err)
+// feed is one RWECC-style endpoint and the source label its incidents are saved under.
+// When Path is set, incidents are read from that local file instead of URL.
+type feed struct {
+	Source string
+	URL    string
+	Path   string
+}
+
+// feeds are the endpoints polled each run, parsed from RWECC_URLS or RWECC_URL.
+var feeds []feed
+
+// sourceName is the source label given to feeds that don't set their own, and the value
+// stored in unified_incidents.source. Overridden by SOURCE_NAME.
+var sourceName = "RWECC"
+
+// parseFeeds splits a comma-separated list of feed URLs. Each entry may be prefixed with
+// "LABEL=" to set its source label; unlabeled entries are labeled sourceName, then
+// sourceName-2, sourceName-3, and so on, so a single-URL setup keeps its original label.
+func parseFeeds(v string) ([]feed, error) {
+	var result []feed
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		f := feed{URL: entry}
+		if label, rest, ok := strings.Cut(entry, "="); ok && !strings.ContainsAny(label, ":/?") {
+			f.Source, f.URL = strings.TrimSpace(label), strings.TrimSpace(rest)
+		}
+		if f.Source == "" {
+			f.Source = sourceName
+			if len(result) > 0 {
+				f.Source = fmt.Sprintf("%s-%d", sourceName, len(result)+1)
+			}
+		}
+		for _, existing := range result {
+			if existing.Source == f.Source {
+				return nil, fmt.Errorf("duplicate feed label %q", f.Source)
+			}
+		}
+		result = append(result, f)
 	}
-	defer db.Close()
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no feed URLs given")
+	}
+	return result, nil
+}
+
+// errUnexpectedPayload means the feed answered with something other than JSON, such as
+// an HTML maintenance page served with a 200 status.
+var errUnexpectedPayload = errors.New("feed returned a non-JSON payload")
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Error connecting to database: %s", err)
+// checkJSONPayload rejects bodies that are declared as HTML or don't start like JSON.
+func checkJSONPayload(contentType string, body []byte) error {
+	var first byte
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 {
+		first = trimmed[0]
 	}
-	log.Println("Successfully connected to the database.")
+	return checkJSONStart(contentType, first)
+}
 
-	apiURL := os.Getenv("RWECC_URL")
-	if apiURL == "" {
-		log.Fatalln("Error: RWECC_URL must be set.")
+// checkJSONStart is checkJSONPayload given only the body's first non-space byte, or zero
+// for an empty body.
+func checkJSONStart(contentType string, first byte) error {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return fmt.Errorf("%w (Content-Type %q)", errUnexpectedPayload, contentType)
+	}
+	if first != '[' && first != '{' {
+		return errUnexpectedPayload
 	}
+	return nil
+}
+
+// errNotModified means the feed answered a conditional request with 304 Not Modified.
+var errNotModified = errors.New("feed not modified since last fetch")
 
-	resp, err := http.Get(apiURL)
+// feedValidators are the cache validators a feed returned with its last payload.
+type feedValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// lastValidators remembers each feed URL's validators between daemon iterations. Runs
+// are sequential, so it needs no locking.
+var lastValidators = make(map[string]feedValidators)
+
+// fetchIncidents downloads and parses one feed, following pagination, and tags each
+// incident with its source. The first page's request is conditional on the validators
+// from the last successful run; a 304 returns errNotModified. The new validators are
+// returned so the caller can store them once the payload has been processed.
+func fetchIncidents(ctx context.Context, f feed) ([]Incident, feedValidators, error) {
+	var validators feedValidators
+	if f.Path != "" {
+		body, err := readPayloadFile(f.Path)
+		if err != nil {
+			return nil, validators, fmt.Errorf("reading input file: %w", err)
+		}
+		incidents, _, err := decodeFeedPage(f, "", bytes.NewReader(body))
+		if err != nil {
+			return nil, validators, err
+		}
+		return tagSource(incidents, f.Source), validators, nil
+	}
+
+	var all []Incident
+	pageURL := firstPageURL(f.URL)
+	for page := 1; ; page++ {
+		var incidents []Incident
+		var next string
+		var pageValidators feedValidators
+		policy := retryPolicy{name: "RWECC " + f.Source, maxRetries: rweccMaxRetries, backoff: rweccBackoffBase, maxRetryAfter: rweccMaxRetryAfter}
+		err := withRetry(ctx, policy, func() error {
+			var err error
+			incidents, next, pageValidators, err = fetchFeedPage(ctx, f, pageURL, page == 1)
+			return err
+		})
+		if err != nil {
+			return nil, validators, err
+		}
+		if page == 1 {
+			validators = pageValidators
+		}
+		all = append(all, incidents...)
+
+		next = nextPageURL(f.URL, pageURL, next, page, len(incidents))
+		if next == "" || next == pageURL {
+			break
+		}
+		if page >= feedMaxPages {
+			slog.Warn("Stopped following feed pages at the page cap, later incidents were not fetched", "source", f.Source, "max_pages", feedMaxPages, "next", next)
+			break
+		}
+		pageURL = next
+	}
+	return tagSource(all, f.Source), validators, nil
+}
+
+// fetchFeedPage GETs one page of a feed and decodes it as it streams in, returning its
+// incidents and next-page link. When conditional is set, the request carries the
+// validators stored for the feed and a 304 returns errNotModified. Network errors, 429s,
+// 5xx responses, and failures reading the body are returned as a *retryableError.
+func fetchFeedPage(ctx context.Context, f feed, pageURL string, conditional bool) ([]Incident, string, feedValidators, error) {
+	var validators feedValidators
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
-		log.Fatalf("Error fetching data from API: %s", err)
+		return nil, "", validators, err
+	}
+	req.Header.Set("User-Agent", rweccUserAgent)
+	rweccAuth.apply(req)
+	if prev, ok := lastValidators[f.URL]; ok && conditional {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	resp, err := rweccClient.Do(req)
+	if err != nil {
+		return nil, "", validators, &retryableError{err: fmt.Errorf("fetching data from API: %w", err)}
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return nil, "", validators, errNotModified
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, "", validators, fmt.Errorf("API returned status: %s (check RWECC_AUTH_BEARER or RWECC_AUTH_BASIC_USER/PASS)", resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, "", validators, &retryableError{err: fmt.Errorf("API returned status: %s", resp.Status), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	case resp.StatusCode >= 500:
+		return nil, "", validators, &retryableError{err: fmt.Errorf("API returned status: %s", resp.Status)}
+	default:
+		// Any other error status may still carry a JSON body, such as {"error": ...},
+		// which would decode as an empty page and look like every incident was gone.
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", validators, fmt.Errorf("API returned status: %s", resp.Status)
+		}
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	// The archive needs the raw bytes, so only then is the body also kept in memory.
+	body := newLimitedBody(resp.Body)
+	var r io.Reader = body
+	var raw bytes.Buffer
+	if rawArchiveDir != "" {
+		r = io.TeeReader(body, &raw)
+	}
+	incidents, next, err := decodeFeedPage(f, resp.Header.Get("Content-Type"), r)
+	if rawArchiveDir != "" && body.err == nil {
+		// The decoder stops early on a bad payload, and may leave trailing bytes on a
+		// good one; the archive gets the whole body either way, so an HTML page or
+		// malformed JSON can be reproduced from it.
+		io.Copy(io.Discard, r)
+	}
+	if body.err != nil {
+		err = fmt.Errorf("reading API response body: %w", body.err)
+		if !errors.Is(body.err, errResponseTooLarge) {
+			err = &retryableError{err: err}
+		}
+		return nil, "", validators, err
+	}
+	if rawArchiveDir != "" {
+		if name, err := archiveRawPayload(f.Source, raw.Bytes()); err != nil {
+			slog.Error("Error archiving raw payload", "source", f.Source, "dir", rawArchiveDir, "error", err)
+		} else {
+			slog.Debug("Archived raw payload", "source", f.Source, "file", name)
+		}
+	}
 	if err != nil {
-		log.Fatalf("Error reading API response body: %s", err)
+		return nil, "", validators, err
 	}
+	validators = feedValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	return incidents, next, validators, nil
+}
 
-	var incidents []Incident
-	if err := json.Unmarshal(body, &incidents); err != nil {
-		log.Fatalf("Error unmarshalling JSON: %s", err)
+// tagSource sets Source on every incident to the feed's label.
+func tagSource(incidents []Incident, source string) []Incident {
+	for i := range incidents {
+		incidents[i].Source = source
 	}
+	return incidents
+}
 
-	log.Println("Searching for new MVC Incidents from RWECC API...")
-	incidentsSaved := 0
+// runOnce fetches every configured feed, filters it, and saves matching incidents.
+// It returns the number of incidents saved. A feed that fails is logged and skipped;
+// an error is only returned when every feed failed. Cancelling ctx aborts the fetch
+// and stops the run after the incident currently being saved. When ADVISORY_LOCK_ID is
+// set and another instance is mid-run, the run is skipped and reports nothing saved.
+func runOnce(ctx context.Context, db *sql.DB, store IncidentStore) (int, error) {
+	release, ok, err := acquireRunLock(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		slog.Info("Another instance holds the run lock, skipping this run", "lock_id", advisoryLockID)
+		return 0, nil
+	}
+	defer release()
+	runDebug.Begin()
+	defer runDebug.Finish()
 
+	timer := prometheus.NewTimer(runDurationSeconds)
+	defer timer.ObserveDuration()
+
+	ctx, span := tracer.Start(ctx, "ingest.run")
+	stats := runStats{StartedAt: time.Now()}
+	total, err := runFeeds(ctx, db, store, &stats)
+	span.SetAttributes(
+		attribute.Int("incidents.fetched", stats.Fetched),
+		attribute.Int("incidents.matched", stats.Matched),
+		attribute.Int("incidents.saved", total))
+	defer endSpan(span, err)
+	slog.Info("Run timing",
+		"total_ms", time.Since(stats.StartedAt).Milliseconds(),
+		"fetch_ms", stats.FetchDuration.Milliseconds(),
+		"enrich_ms", stats.EnrichDuration.Milliseconds(),
+		"db_ms", stats.DBDuration.Milliseconds())
+	summary := []any{
+		"ok", stats.WeatherOK,
+		"no_coverage", stats.WeatherNoCoverage,
+		"error", stats.WeatherErrors,
+	}
+	if stats.WeatherFetches > 0 {
+		summary = append(summary,
+			"fetch_ms_min", stats.WeatherFetchMin.Milliseconds(),
+			"fetch_ms_avg", (stats.WeatherFetchTotal / time.Duration(stats.WeatherFetches)).Milliseconds(),
+			"fetch_ms_max", stats.WeatherFetchMax.Milliseconds())
+	}
+	slog.Info("Weather enrichment summary", summary...)
+	if !dryRun && dbDriver == driverPostgres {
+		if aerr := recordRun(context.WithoutCancel(ctx), db, stats, err); aerr != nil {
+			dbErrorsTotal.Inc()
+			slog.Error("Error writing ingestion run audit row", "error", aerr)
+		}
+	}
+	return total, err
+}
+
+// runTimeout bounds a whole run so a pathological one can't overrun the poll schedule.
+// Zero means no limit. Overridden by RUN_TIMEOUT.
+var runTimeout time.Duration
+
+// errRunTimeout is the cancellation cause of a run that exceeded runTimeout.
+var errRunTimeout = errors.New("run exceeded RUN_TIMEOUT")
+
+// runWithTimeout calls runOnce, cancelling it once runTimeout elapses. A cancelled run
+// stops like a shutdown does: incidents already saved stay saved.
+func runWithTimeout(ctx context.Context, db *sql.DB, store IncidentStore) (int, error) {
+	if runTimeout <= 0 {
+		return runOnce(ctx, db, store)
+	}
+	runCtx, cancel := context.WithTimeoutCause(ctx, runTimeout, errRunTimeout)
+	defer cancel()
+	total, err := runOnce(runCtx, db, store)
+	if errors.Is(context.Cause(runCtx), errRunTimeout) {
+		slog.Warn("Run exceeded RUN_TIMEOUT and was cancelled", "run_timeout", runTimeout, "saved", total)
+	}
+	return total, err
+}
+
+// runFeeds processes each feed in turn, accumulating into stats.
+func runFeeds(ctx context.Context, db *sql.DB, store IncidentStore, stats *runStats) (int, error) {
+	total, failures := 0, 0
+	perSource := make(map[string]int, len(feeds))
+	var stored []enrichedIncident
+	var lastErr error
+	resetUnmatchedProblems()
+	for _, f := range feeds {
+		if ctx.Err() != nil {
+			break
+		}
+		saved, rows, err := processFeed(ctx, db, store, f, stats)
+		total += saved
+		perSource[f.Source] = saved
+		stored = append(stored, rows...)
+		if err != nil {
+			failures++
+			lastErr = err
+			slog.Error("Error processing feed", "source", f.Source, "url", redactURL(f.URL), "path", f.Path, "error", err)
+		}
+	}
+	notifyClusters(stored)
+	waitForWebhooks()
+
+	// A feed that wasn't modified or failed keeps its last exported incidents, and the
+	// file is only rewritten when some feed returned a payload.
+	if geojsonOut != "" && geojsonStale {
+		if err := writeGeoJSON(geojsonOut, geojsonRows()); err != nil {
+			slog.Error("Error writing GeoJSON export", "path", geojsonOut, "error", err)
+		} else {
+			geojsonStale = false
+		}
+	}
+	if failures == len(feeds) {
+		return total, lastErr
+	}
+
+	if ctx.Err() != nil {
+		slog.Info("Run cancelled, stopped early", "reason", context.Cause(ctx), "saved", total, "per_source", perSource)
+	} else if dryRun {
+		slog.Info("Dry run complete, no rows were written", "would_save", total, "per_source", perSource)
+	} else {
+		slog.Info("Run complete", "saved", total, "per_source", perSource)
+	}
+	return total, nil
+}
+
+// processFeed runs one feed through filtering, enrichment, and saving, adding its counts
+// to stats. It returns the number of incidents saved and the enriched incidents it
+// stored, which is none if the final flush failed.
+func processFeed(ctx context.Context, db *sql.DB, store IncidentStore, f feed, stats *runStats) (int, []enrichedIncident, error) {
+	fetchStart := time.Now()
+	fetchCtx, span := tracer.Start(ctx, "feed.fetch", trace.WithAttributes(attribute.String("feed.source", f.Source)))
+	incidents, validators, err := fetchIncidents(fetchCtx, f)
+	span.SetAttributes(attribute.Int("incidents.fetched", len(incidents)))
+	endSpan(span, err)
+	stats.FetchDuration += time.Since(fetchStart)
+	if errors.Is(err, errNotModified) {
+		slog.Info("Feed not modified since last fetch, skipping", "source", f.Source)
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	incidentsFetchedTotal.Add(float64(len(incidents)))
+	stats.Fetched += len(incidents)
+	runDebug.Fetched(f.Source, len(incidents))
+
+	slog.Info("Searching for new incidents", "source", f.Source, "filters", incidentFilters, "fetched", len(incidents))
+	// The feed occasionally repeats an incident within one payload, so each source_id
+	// is only processed once per run.
+	var matched []Incident
+	seen := make(map[string]bool)
+	duplicates, blocklistSkipped, jurisdictionSkipped, bboxSkipped := 0, 0, 0, 0
 	for _, incident := range incidents {
-		if strings.Contains(incident.Problem, "MVC") {
-			if err := saveToUnifiedDB(db, incident); err != nil {
-				log.Printf("Error saving incident for '%s': %v", incident.Address, err)
-			} else {
-				incidentsSaved++
+		if !matchesFilters(incident.Problem, incidentFilters) {
+			runDebug.Skipped(incident, "filter")
+			continue
+		}
+		if blocklisted(incident) {
+			blocklistSkipped++
+			runDebug.Skipped(incident, "blocklist")
+			continue
+		}
+		if !jurisdictionAllowed(incident.Jurisdiction) {
+			jurisdictionSkipped++
+			runDebug.Skipped(incident, "jurisdiction")
+			continue
+		}
+		if bbox != nil && !bbox.contains(incident.Lat, incident.Long) {
+			bboxSkipped++
+			runDebug.Skipped(incident, "bbox")
+			continue
+		}
+		if needsAddress(incident.Address) {
+			fillMissingAddress(ctx, &incident)
+		}
+		id := sourceIDFor(incident)
+		if seen[id] {
+			duplicates++
+			runDebug.Skipped(incident, "duplicate")
+			continue
+		}
+		seen[id] = true
+		matched = append(matched, incident)
+		runDebug.Matched(incident)
+	}
+	if duplicates > 0 {
+		slog.Info("Collapsed duplicate incidents in payload", "source", f.Source, "duplicates", duplicates)
+	}
+	if blocklistSkipped > 0 {
+		slog.Info("Skipped test incidents matching ADDRESS_BLOCKLIST", "source", f.Source, "skipped", blocklistSkipped)
+	}
+	if jurisdictionSkipped > 0 {
+		slog.Info("Skipped incidents by jurisdiction filter", "source", f.Source, "skipped", jurisdictionSkipped)
+	}
+	if bboxSkipped > 0 {
+		slog.Info("Skipped incidents outside bounding box", "source", f.Source, "skipped", bboxSkipped)
+	}
+	stats.Matched += len(matched)
+
+	// Incidents whose content hasn't changed since they were stored need neither a
+	// weather lookup nor a rewrite. Those processed within the last few polls are caught
+	// in memory first; the rest are checked against the stored content hashes, except in
+	// SQLite databases, which are for local runs.
+	toProcess, recent := skipSeen(matched)
+	if recent > 0 {
+		slog.Info("Skipped recently processed incidents", "source", f.Source, "recent", recent)
+	}
+	unchanged := 0
+	if dbDriver == driverPostgres {
+		toProcess, unchanged, err = skipUnchanged(ctx, db, f.Source, toProcess)
+	}
+	if err != nil {
+		slog.Warn("Could not check content hashes, processing all matched incidents", "source", f.Source, "error", err)
+	} else if unchanged > 0 {
+		slog.Info("Skipped unchanged incidents", "source", f.Source, "unchanged", unchanged)
+	}
+	unchanged += recent
+
+	limitSkipped := 0
+	if processLimit > 0 {
+		remaining := max(processLimit-stats.Processed, 0)
+		if len(toProcess) > remaining {
+			limitSkipped = len(toProcess) - remaining
+			slog.Info("Process limit reached, skipping remaining incidents", "source", f.Source, "limit", processLimit, "skipped", limitSkipped)
+			toProcess = toProcess[:remaining]
+		}
+	}
+	runDebug.Counts(unchanged, limitSkipped)
+	stats.Processed += len(toProcess)
+
+	// Weather is fetched concurrently, but saves happen one at a time here. Incidents
+	// already being enriched when shutdown is requested are still saved; the grace
+	// deadline in main bounds how long that can take.
+	saveCtx := context.WithoutCancel(ctx)
+	var processed, stored []enrichedIncident
+	for result := range enrichIncidents(ctx, toProcess, weatherWorkers) {
+		processed = append(processed, result)
+		switch result.weatherStatus {
+		case weatherStatusOK:
+			stats.WeatherOK++
+		case weatherStatusNoCoverage:
+			stats.WeatherNoCoverage++
+		case weatherStatusDisabled:
+		default:
+			stats.WeatherErrors++
+		}
+		stats.EnrichDuration += result.enrichDuration
+		if result.weatherDuration > 0 {
+			stats.observeWeatherFetch(result.weatherDuration)
+		}
+		writeStart := time.Now()
+		writeCtx, span := tracer.Start(withCorrelationID(saveCtx, result.incident), "db.save", incidentAttributes(result.incident))
+		err := store.Save(writeCtx, result)
+		endSpan(span, err)
+		if err != nil {
+			slog.ErrorContext(writeCtx, "Error saving incident", "source", f.Source, "incident_address", result.incident.Address, "jurisdiction", result.incident.Jurisdiction, "error", err)
+		} else {
+			stored = append(stored, result)
+		}
+		stats.DBDuration += time.Since(writeStart)
+	}
+	if geojsonOut != "" {
+		updateGeoJSONFeed(f.Source, matched, processed)
+	}
+	closeStart := time.Now()
+	flushCtx, span := tracer.Start(saveCtx, "db.flush", trace.WithAttributes(attribute.String("feed.source", f.Source)))
+	saved, err := store.Flush(flushCtx)
+	endSpan(span, err)
+	stats.DBDuration += time.Since(closeStart)
+	stats.Saved += saved
+	if err != nil {
+		return saved, nil, err
+	}
+	// Failed weather lookups aren't remembered, so the next poll retries them.
+	if !dryRun {
+		for _, row := range stored {
+			if row.weatherStatus != weatherStatusError {
+				seenIncidents.Add(row.incident)
 			}
 		}
 	}
 
-	log.Printf("Run complete. Processed and saved %d MVC incidents to the unified table.", incidentsSaved)
+	// Only a complete pass knows which incidents are really gone from the feed. An empty
+	// payload is more likely a feed glitch than every incident clearing at once, so it
+	// resolves nothing unless RESOLVE_ON_EMPTY_FEED says otherwise.
+	if len(incidents) == 0 && !resolveOnEmptyFeed {
+		slog.Warn("Feed returned no incidents, not resolving stored incidents", "source", f.Source)
+	} else if ctx.Err() == nil && !dryRun && dbDriver == driverPostgres {
+		seenIDs := make([]string, 0, len(seen))
+		for id := range seen {
+			seenIDs = append(seenIDs, id)
+		}
+		resolved, err := resolveMissingIncidents(saveCtx, db, f.Source, seenIDs)
+		if err != nil {
+			dbErrorsTotal.Inc()
+			slog.Error("Error resolving incidents missing from feed", "source", f.Source, "error", err)
+		} else {
+			slog.Info("Resolved incidents missing from feed", "source", f.Source, "resolved", resolved, "resolve_after_runs", resolveAfter)
+		}
+	}
+
+	// Validators are only kept once the payload has been fully processed, so a failed
+	// run isn't skipped as "not modified" on the next poll.
+	if ctx.Err() == nil && f.URL != "" {
+		lastValidators[f.URL] = validators
+	}
+
+	slog.Info("Feed complete", "source", f.Source, "saved", saved, "matched", len(matched))
+	return saved, stored, nil
+}
+
+func main() {
+	flag.Parse()
+	if *versionFlag {
+		fmt.Println(versionString())
+		return
+	}
+
+	envErr := godotenv.Load()
+	cfg, err := LoadConfig(*configFlag)
+	if err != nil {
+		// LOG_LEVEL may be what's invalid, so the error is logged at the default level.
+		setupLogger(os.Getenv("LOG_FORMAT"), slog.LevelInfo)
+		fatal("Invalid configuration", "error", err)
+	}
+	level, _ := parseLogLevel(cfg.LogLevel)
+	setupLogger(cfg.LogFormat, level)
+	logBuildInfo()
+	if envErr != nil {
+		slog.Info("Note: .env file not found")
+	}
+	slog.Info("Effective configuration", "config", cfg)
+
+	dryRun = cfg.DryRun
+	if dryRun {
+		slog.Info("Dry run enabled, no database writes will be made")
+	}
+	processLimit = cfg.ProcessLimit
+
+	dbDriver = cfg.DBDriver
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
+		cfg.DatabaseHost, cfg.DatabasePort, cfg.DatabaseUsername, cfg.DatabasePassword, cfg.DatabaseName)
+	if dbDriver == driverSQLite {
+		dsn = cfg.DatabaseName
+		// SQLite allows one writer; a single connection avoids "database is locked".
+		cfg.DBMaxOpen, cfg.DBMaxIdle = 1, 1
+		slog.Info("Using a local SQLite database instead of Postgres", "path", dsn)
+	}
+
+	db, err := sql.Open(dbDriver, dsn)
+	if err != nil {
+		fatal("Error opening database", "error", err)
+	}
+	defer db.Close()
+
+	advisoryLockID = cfg.AdvisoryLockID
+	db.SetMaxOpenConns(cfg.DBMaxOpen)
+	db.SetMaxIdleConns(cfg.DBMaxIdle)
+	db.SetConnMaxLifetime(cfg.DBConnLifetime.Duration)
+	slog.Info("Configured database connection pool", "max_open", cfg.DBMaxOpen, "max_idle", cfg.DBMaxIdle, "conn_lifetime", cfg.DBConnLifetime)
+
+	sourceName = strings.TrimSpace(cfg.SourceName)
+	if cfg.InputFile != "" {
+		feeds = []feed{{Source: sourceName, Path: cfg.InputFile}}
+		slog.Info("Reading incidents from input file instead of the live feed", "path", cfg.InputFile)
+	} else {
+		feeds, err = parseFeeds(cfg.RWECCURLs)
+		if err != nil {
+			fatal("Invalid RWECC_URL(S)", "error", err)
+		}
+	}
+
+	if cfg.ProxyURL != "" {
+		if err := setProxyURL(cfg.ProxyURL); err != nil {
+			fatal("Invalid PROXY_URL", "error", err)
+		}
+		slog.Info("Routing outbound HTTP requests through proxy")
+	}
+	rweccClient.Timeout = cfg.RWECCTimeout.Duration
+	rweccAuth = feedAuth{Bearer: cfg.RWECCAuthBearer, BasicUser: cfg.RWECCAuthBasicUser, BasicPass: cfg.RWECCAuthBasicPass}
+	feedPageSize = cfg.RWECCPageSize
+	feedMaxPages = cfg.RWECCMaxPages
+	rweccMaxRetries = cfg.RWECCMaxRetries
+	weatherMaxRetries = cfg.WeatherMaxRetries
+	weatherWorkers = cfg.WeatherWorkers
+	batchSize = cfg.BatchSize
+	resolveAfter = cfg.ResolveAfter
+	resolveOnEmptyFeed = cfg.ResolveEmptyFeed
+	retentionDays = cfg.RetentionDays
+	enableNWSAlerts = cfg.EnableNWSAlerts
+	enableWeather = cfg.EnableWeather
+	if !enableWeather {
+		slog.Info("Weather enrichment disabled, incidents will be saved without weather")
+	}
+	nwsUserAgent = cfg.NWSUserAgent
+	if nwsUserAgent == defaultNWSUserAgent && enableWeather {
+		// A daemon is a production deployment, which NWS expects to be identifiable.
+		if cfg.PollInterval.Duration > 0 && !dryRun {
+			fatal("NWS_USER_AGENT is unset; set it to this deployment's name and contact info, e.g. \"(myapp, ops@example.com)\"")
+		}
+		slog.Warn("NWS_USER_AGENT is unset, using the default; set it to this deployment's name and contact info", "user_agent", nwsUserAgent)
+	}
+	if path := cfg.EventTypeMap; path != "" {
+		m, err := loadClassificationMap(path)
+		if err != nil {
+			fatal("Error loading EVENT_TYPE_MAP", "path", path, "error", err)
+		}
+		if len(m.EventTypes) > 0 {
+			eventTypeRules = m.EventTypes
+		}
+		if len(m.Severities) > 0 {
+			severityRules = m.Severities
+		}
+		statusRules = m.Statuses
+	}
+	webhookURL = cfg.WebhookURL
+	notifyFormat = cfg.NotifyFormat
+	geojsonOut = cfg.GeoJSONOut
+	clusterThreshold = cfg.ClusterThreshold
+	clusterWindow = cfg.ClusterWindow.Duration
+	rawArchiveDir = cfg.RawArchiveDir
+	incidentHistory = cfg.IncidentHistory
+	if cfg.BrokerURL != "" && !dryRun {
+		publisher, err = newPublisher(cfg.BrokerURL)
+		if err != nil {
+			fatal("Error connecting to BROKER_URL", "error", err)
+		}
+		publishTopic = cfg.BrokerTopic
+		startPublisher()
+		defer stopPublisher(cfg.ShutdownGrace.Duration)
+		slog.Info("Publishing committed incidents to message bus", "topic", publishTopic)
+	}
+	rawArchiveKeep = cfg.RawArchiveKeep
+	geocoderURL = cfg.GeocoderURL
+	maxResponseBytes = cfg.MaxResponseBytes
+	detailsMaxBytes = cfg.DetailsMaxBytes
+	incidentLocation, err = time.LoadLocation(cfg.IncidentTimezone)
+	if err != nil {
+		fatal("INCIDENT_TIMEZONE is not a valid IANA time zone", "value", cfg.IncidentTimezone, "error", err)
+	}
+	weatherUnits = cfg.WeatherUnits
+	conflictMode = cfg.ConflictMode
+	tempMinF, tempMaxF = cfg.TempMinF, cfg.TempMaxF
+	coordPrecision = cfg.CoordPrecision
+	nwsBaseURL = cfg.NWSBaseURL
+	forecastCache = newForecastURLCache(cfg.WeatherCacheTTL.Duration)
+	weatherSource, err = newWeatherSource(cfg.WeatherSource, db)
+	if err != nil {
+		fatal("Invalid WEATHER_SOURCE", "error", err)
+	}
+	if _, live := weatherSource.(nwsWeatherSource); !live {
+		slog.Info("Using historical weather source instead of the live NWS API", "weather_source", cfg.WeatherSource)
+	}
+	fallbackWeather, err = newFallbackWeather(cfg.FallbackWeather, cfg.OpenWeatherMapKey)
+	if err != nil {
+		fatal("Invalid FALLBACK_WEATHER", "error", err)
+	}
+	if cfg.NWSRateLimit > 0 {
+		nwsLimiter = rate.NewLimiter(rate.Limit(cfg.NWSRateLimit), max(1, int(cfg.NWSRateLimit)))
+	} else {
+		nwsLimiter = rate.NewLimiter(rate.Inf, 0)
+	}
+
+	incidentFilters, err = parseIncidentFilters(cfg.IncidentFilters)
+	if err != nil {
+		fatal("Invalid INCIDENT_FILTERS", "error", err)
+	}
+	addressBlocklist = parseAddressBlocklist(cfg.AddressBlocklist)
+	jurisdictionAllow = parseJurisdictionSet(cfg.JurisdictionAllow)
+	jurisdictionDeny = parseJurisdictionSet(cfg.JurisdictionDeny)
+	bbox, err = parseBoundingBox(cfg.BBox)
+	if err != nil {
+		fatal("Invalid BBOX", "error", err)
+	}
+	if len(jurisdictionAllow) > 0 && len(jurisdictionDeny) > 0 {
+		slog.Warn("Both JURISDICTION_ALLOW and JURISDICTION_DENY are set; the allow list takes precedence")
+	}
+
+	if *selftestFlag {
+		if !runSelfTest(context.Background(), db) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := pingWithRetry(db, cfg.DBConnectRetries); err != nil {
+		fatal("Error connecting to database", "error", err)
+	}
+	slog.Info("Successfully connected to the database")
+
+	if dbDriver == driverSQLite {
+		if err := ensureSQLiteSchema(context.Background(), db); err != nil {
+			fatal("Error preparing SQLite database", "error", err)
+		}
+	}
+	if cfg.RunMigrations && dbDriver == driverPostgres {
+		if err := runMigrations(context.Background(), db); err != nil {
+			fatal("Error running migrations", "error", err)
+		}
+		slog.Info("Applied unified_incidents migrations")
+	}
+	if !cfg.SkipSchemaCheck && dbDriver == driverPostgres {
+		missing, err := missingColumns(context.Background(), db)
+		if err != nil {
+			fatal("Error checking unified_incidents schema", "error", err)
+		}
+		if len(missing) > 0 {
+			fatal("unified_incidents is missing required columns; add them before running the ingestor (or set SKIP_SCHEMA_CHECK=true)", "missing_columns", missing)
+		}
+		ok, err := hasConflictConstraint(context.Background(), db)
+		if err != nil {
+			fatal("Error checking unified_incidents schema", "error", err)
+		}
+		if !ok && !cfg.RunMigrations {
+			fatal("Missing unique constraint", "error", errMissingConflictConstraint)
+		}
+		if !ok {
+			if _, err := db.ExecContext(context.Background(), addConflictConstraintSQL); err != nil {
+				fatal("Error adding the (source, source_id) constraint; remove duplicate rows and retry", "error", err)
+			}
+			slog.Info("Added the missing unique constraint on unified_incidents (source, source_id)")
+		}
+	}
+
+	if *reenrichFlag {
+		if dbDriver != driverPostgres {
+			fatal("--reenrich requires DB_DRIVER=postgres")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := reenrichIncidents(ctx, db, *reenrichSince)
+		stop()
+		if err != nil {
+			fatal("Error re-enriching incidents", "error", err)
+		}
+		return
+	}
+
+	if *cleanupFlag {
+		// Deleting is opt-in twice over: the flag runs it, and RETENTION_DAYS says how much.
+		if retentionDays == 0 {
+			fatal("--cleanup requires RETENTION_DAYS to be set")
+		}
+		if dbDriver != driverPostgres {
+			fatal("--cleanup requires DB_DRIVER=postgres")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := purgeResolvedIncidents(ctx, db)
+		stop()
+		if err != nil {
+			fatal("Error deleting resolved incidents", "error", err)
+		}
+		return
+	}
+
+	pollInterval := cfg.PollInterval.Duration
+	runTimeout = cfg.RunTimeout.Duration
+	shutdownGrace := cfg.ShutdownGrace.Duration
+
+	if !dryRun && dbDriver == driverPostgres {
+		if err := ensureIngestionRunsTable(context.Background(), db); err != nil {
+			fatal("Error preparing audit table", "error", err)
+		}
+		if incidentHistory {
+			if err := ensureIncidentHistoryTable(context.Background(), db); err != nil {
+				fatal("Error preparing incident history table", "error", err)
+			}
+		}
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err := setupTracing(context.Background(), cfg.OTLPEndpoint)
+		if err != nil {
+			fatal("Error setting up tracing", "error", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error("Error flushing traces", "error", err)
+			}
+		}()
+		slog.Info("Exporting OpenTelemetry traces", "endpoint", cfg.OTLPEndpoint)
+	}
+	if cfg.MetricsAddr != "" {
+		startMetricsServer(cfg.MetricsAddr)
+	}
+	if cfg.HealthAddr != "" {
+		startHealthServer(cfg.HealthAddr, db, 2*pollInterval)
+	}
+	if cfg.DebugAddr != "" {
+		startDebugServer(cfg.DebugAddr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutdown signal received, finishing current work", "grace_period", shutdownGrace)
+		time.Sleep(shutdownGrace)
+		fatal("Shutdown grace period exceeded, forcing exit")
+	}()
+
+	var store IncidentStore = newPostgresStore(db, batchSize)
+	if dbDriver == driverSQLite {
+		store = newSQLiteStore(db, batchSize)
+	}
+	if cfg.Bulk {
+		store = newBulkStore(db)
+		slog.Info("Bulk load mode enabled, incidents will be loaded with COPY")
+	}
+	if cfg.AnalyticsDSN != "" && !dryRun {
+		analyticsDB, err := sql.Open("postgres", cfg.AnalyticsDSN)
+		if err != nil {
+			fatal("Error opening analytics database", "error", err)
+		}
+		defer analyticsDB.Close()
+		if cfg.RunMigrations {
+			if err := runMigrations(context.Background(), analyticsDB); err != nil {
+				slog.Warn("Error running migrations on analytics database", "error", err)
+			}
+		}
+		store = teeStore{primary: store, secondary: newSecondaryPostgresStore(analyticsDB, batchSize)}
+		slog.Info("Copying incidents to the analytics database")
+	}
+	if pollInterval == 0 {
+		_, err := runWithTimeout(ctx, db, store)
+		health.Record(err)
+		if errors.Is(err, errUnexpectedPayload) {
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			fatal("Run failed", "error", err)
+		}
+		return
+	}
+
+	pollJitter = cfg.PollJitter
+	if cfg.SeenCacheSize > 0 {
+		seenIncidents = newSeenCache(cfg.SeenCacheSize, cfg.SeenCacheTTL.Duration)
+	}
+	slog.Info("Running in daemon mode", "poll_interval", pollInterval, "poll_jitter", pollJitter)
+	for {
+		_, err := runWithTimeout(ctx, db, store)
+		health.Record(err)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Error during run, will retry next poll", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Daemon stopped")
+			return
+		case <-time.After(jitteredInterval(pollInterval)):
+		}
+	}
 }
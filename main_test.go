@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fakeNWS serves points and hourly forecast responses for getWeatherForIncident. Each
+// handler writes its own status and body; a nil handler answers 404.
+type fakeNWS struct {
+	points func(w http.ResponseWriter, r *http.Request, hourlyURL string)
+	hourly func(w http.ResponseWriter, r *http.Request)
+}
+
+// newFakeNWS starts an httptest server for f and points nwsBaseURL at it, with a fresh
+// forecast cache and no rate limit, restoring them when the test ends.
+func newFakeNWS(t *testing.T, f fakeNWS) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/points/", func(w http.ResponseWriter, r *http.Request) {
+		if f.points == nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.points(w, r, srv.URL+"/gridpoints/RAH/1,2/forecast/hourly")
+	})
+	mux.HandleFunc("/gridpoints/", func(w http.ResponseWriter, r *http.Request) {
+		if f.hourly == nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.hourly(w, r)
+	})
+	srv = httptest.NewServer(mux)
+
+	prevBase, prevCache, prevLimiter := nwsBaseURL, forecastCache, nwsLimiter
+	nwsBaseURL, forecastCache = srv.URL, newForecastURLCache(time.Hour)
+	nwsLimiter = rate.NewLimiter(rate.Inf, 0)
+	t.Cleanup(func() {
+		srv.Close()
+		nwsBaseURL, forecastCache, nwsLimiter = prevBase, prevCache, prevLimiter
+	})
+	return srv
+}
+
+func pointsOK(w http.ResponseWriter, _ *http.Request, hourlyURL string) {
+	fmt.Fprintf(w, `{"properties":{"forecastHourly":%q,"gridId":"RAH","gridX":1,"gridY":2}}`, hourlyURL)
+}
+
+func hourlyOK(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprint(w, `{"properties":{"periods":[{"temperature":72,"temperatureUnit":"F","windSpeed":"5 mph","windDirection":"SW","shortForecast":"Sunny","icon":"https://example.com/sunny","startTime":"2024-05-01T10:00:00-04:00","endTime":"2024-05-01T11:00:00-04:00"}]}}`)
+}
+
+func TestGetWeatherForIncident(t *testing.T) {
+	tests := []struct {
+		name    string
+		nws     fakeNWS
+		wantErr string
+		// wantErrIs, when set, must match the error with errors.Is.
+		wantErrIs error
+	}{
+		{
+			name: "success",
+			nws:  fakeNWS{points: pointsOK, hourly: hourlyOK},
+		},
+		{
+			name:      "points not found",
+			nws:       fakeNWS{hourly: hourlyOK},
+			wantErr:   "404",
+			wantErrIs: ErrOutsideCoverage,
+		},
+		{
+			name: "points bad request",
+			nws: fakeNWS{
+				points: func(w http.ResponseWriter, _ *http.Request, _ string) {
+					http.Error(w, "bad point", http.StatusBadRequest)
+				},
+				hourly: hourlyOK,
+			},
+			wantErr: "400",
+		},
+		{
+			name: "points without forecast URL",
+			nws: fakeNWS{
+				points: func(w http.ResponseWriter, _ *http.Request, _ string) {
+					fmt.Fprint(w, `{"properties":{"gridId":"RAH"}}`)
+				},
+				hourly: hourlyOK,
+			},
+			wantErr: "did not contain a forecast URL",
+		},
+		{
+			name: "empty periods",
+			nws: fakeNWS{
+				points: pointsOK,
+				hourly: func(w http.ResponseWriter, _ *http.Request) {
+					fmt.Fprint(w, `{"properties":{"periods":[]}}`)
+				},
+			},
+			wantErr: "no weather periods",
+		},
+		{
+			name: "malformed points JSON",
+			nws: fakeNWS{
+				points: func(w http.ResponseWriter, _ *http.Request, _ string) {
+					fmt.Fprint(w, `{"properties":`)
+				},
+				hourly: hourlyOK,
+			},
+			wantErr: "failed to unmarshal NWS points JSON",
+		},
+		{
+			name: "malformed hourly JSON",
+			nws: fakeNWS{
+				points: pointsOK,
+				hourly: func(w http.ResponseWriter, _ *http.Request) {
+					fmt.Fprint(w, `{"properties":{"periods":[{"temperature":"warm"}]}}`)
+				},
+			},
+			wantErr: "failed to unmarshal NWS hourly JSON",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeNWS(t, tt.nws)
+			weather, err := getWeatherForIncident(context.Background(), 35.78, -78.64)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("err = %v, want errors.Is %v", err, tt.wantErrIs)
+				}
+				if weather != nil {
+					t.Errorf("weather = %+v, want nil on error", weather)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getWeatherForIncident: %v", err)
+			}
+			want := WeatherData{
+				Temperature:     72,
+				TemperatureUnit: "F",
+				WindSpeed:       "5 mph",
+				WindDirection:   "SW",
+				ShortForecast:   "Sunny",
+				Icon:            "https://example.com/sunny",
+				Provider:        providerNWS,
+			}
+			got := *weather
+			if got.Grid == nil || *got.Grid != (NWSGrid{GridID: "RAH", GridX: 1, GridY: 2}) {
+				t.Errorf("Grid = %+v, want RAH 1,2", got.Grid)
+			}
+			if got.StartTime.IsZero() || !got.EndTime.After(got.StartTime) {
+				t.Errorf("StartTime, EndTime = %v, %v, want the period's bounds", got.StartTime, got.EndTime)
+			}
+			got.Grid, got.StartTime, got.EndTime = nil, time.Time{}, time.Time{}
+			if got != want {
+				t.Errorf("weather = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetWeatherForIncidentRedirectKeepsUserAgent(t *testing.T) {
+	prevUA := nwsUserAgent
+	nwsUserAgent = "rwecc-test (ops@example.com)"
+	t.Cleanup(func() { nwsUserAgent = prevUA })
+
+	var hourlyURL, gotUA string
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		pointsOK(w, r, hourlyURL)
+	}))
+	t.Cleanup(moved.Close)
+
+	srv := newFakeNWS(t, fakeNWS{
+		points: func(w http.ResponseWriter, r *http.Request, _ string) {
+			http.Redirect(w, r, moved.URL+r.URL.Path, http.StatusMovedPermanently)
+		},
+		hourly: hourlyOK,
+	})
+	hourlyURL = srv.URL + "/gridpoints/RAH/1,2/forecast/hourly"
+
+	if _, err := getWeatherForIncident(context.Background(), 35.78, -78.64); err != nil {
+		t.Fatalf("getWeatherForIncident: %v", err)
+	}
+	if gotUA != nwsUserAgent {
+		t.Errorf("redirected User-Agent = %q, want %q", gotUA, nwsUserAgent)
+	}
+}
+
+func TestBuildUnifiedRowWeatherColumns(t *testing.T) {
+	full := map[string]sql.NullString{
+		"weather_wind_speed": {String: "5 mph", Valid: true},
+		"weather_wind_dir":   {String: "SW", Valid: true},
+		"weather_forecast":   {String: "Sunny", Valid: true},
+		"weather_icon":       {String: "https://example.com/sunny", Valid: true},
+	}
+	empty := map[string]sql.NullString{
+		"weather_wind_speed": {},
+		"weather_wind_dir":   {},
+		"weather_forecast":   {},
+		"weather_icon":       {},
+	}
+	tests := []struct {
+		name    string
+		weather WeatherData
+		want    map[string]sql.NullString
+	}{
+		{
+			name:    "temperature only",
+			weather: WeatherData{Temperature: 70, TemperatureUnit: "F"},
+			want:    empty,
+		},
+		{
+			name:    "blank fields",
+			weather: WeatherData{Temperature: 70, TemperatureUnit: "F", WindSpeed: " ", WindDirection: "\t", ShortForecast: " ", Icon: " "},
+			want:    empty,
+		},
+		{
+			name: "full period",
+			weather: WeatherData{
+				Temperature: 72, TemperatureUnit: "F", WindSpeed: "5 mph", WindDirection: "SW",
+				ShortForecast: "Sunny", Icon: "https://example.com/sunny",
+			},
+			want: full,
+		},
+	}
+	prevLoc := incidentLocation
+	incidentLocation = time.UTC
+	t.Cleanup(func() { incidentLocation = prevLoc })
+
+	column := make(map[string]int, len(upsertColumns))
+	for i, name := range upsertColumns {
+		column[name] = i
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather := tt.weather
+			row := enrichedIncident{
+				incident: Incident{Jurisdiction: "Raleigh", Problem: "MVC", Address: "1 Main St", Lat: 35.78, Long: -78.64, Timestamp: "2024-05-01 10:00:00"},
+				weather:  &weather,
+			}
+			args, err := buildUnifiedRow(row)
+			if err != nil {
+				t.Fatalf("buildUnifiedRow: %v", err)
+			}
+			if len(args) != len(upsertColumns) {
+				t.Fatalf("got %d args, want one per upsertColumns (%d)", len(args), len(upsertColumns))
+			}
+			if got := args[column["weather_temp"]]; got != (sql.NullInt32{Int32: int32(weather.Temperature), Valid: true}) {
+				t.Errorf("weather_temp = %#v, want %v", got, weather.Temperature)
+			}
+			for name, want := range tt.want {
+				got, ok := args[column[name]].(sql.NullString)
+				if !ok || got.Valid != want.Valid || (want.Valid && got.String != want.String) {
+					t.Errorf("%s = %#v, want %#v", name, args[column[name]], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchFeedPageAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    feedAuth
+		wantErr string
+	}{
+		{name: "bearer", auth: feedAuth{Bearer: "s3cret"}},
+		{name: "basic", auth: feedAuth{BasicUser: "rwecc", BasicPass: "hunter2"}},
+		{name: "wrong bearer", auth: feedAuth{Bearer: "stale"}, wantErr: "RWECC_AUTH_"},
+		{name: "no credentials", wantErr: "RWECC_AUTH_"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, basic := r.BasicAuth()
+		switch {
+		case r.Header.Get("Authorization") == "Bearer s3cret":
+		case basic && user == "rwecc" && pass == "hunter2":
+		default:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"jurisdiction":"Raleigh","problem":"MVC","address":"1 Main St","lat":35.78,"long":-78.64,"timestamp":"2024-05-01 10:00:00"}]`)
+	}))
+	t.Cleanup(srv.Close)
+
+	prevAuth := rweccAuth
+	t.Cleanup(func() { rweccAuth = prevAuth })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rweccAuth = tt.auth
+			f := feed{Source: sourceName, URL: srv.URL}
+			incidents, _, _, err := fetchFeedPage(context.Background(), f, srv.URL, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				var retryErr *retryableError
+				if errors.As(err, &retryErr) {
+					t.Errorf("err = %v, want an auth failure not to be retried", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetchFeedPage: %v", err)
+			}
+			if len(incidents) != 1 {
+				t.Errorf("got %d incidents, want 1", len(incidents))
+			}
+		})
+	}
+}
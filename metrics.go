@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	incidentsFetchedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "incidents_fetched_total",
+		Help: "Incidents returned by the RWECC feed, before filtering.",
+	})
+	incidentsSavedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "incidents_saved_total",
+		Help: "Incidents committed to the unified table.",
+	})
+	weatherFetchErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_fetch_errors_total",
+		Help: "Incidents whose NWS weather lookup failed.",
+	})
+	dbErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "db_errors_total",
+		Help: "Failed incident upserts and batch commits.",
+	})
+	weatherCacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_cache_hits_total",
+		Help: "NWS points lookups answered from the forecast URL cache.",
+	})
+	weatherCacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_cache_misses_total",
+		Help: "NWS points lookups not in the forecast URL cache, or expired there.",
+	})
+	singleflightSharedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "singleflight_shared_total",
+		Help: "Weather lookups whose result was shared with a concurrent lookup for the same point, counting every caller.",
+	})
+	publishFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "publish_failures_total",
+		Help: "Committed incidents the message bus publish failed for.",
+	})
+	publishDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "publish_dropped_total",
+		Help: "Committed incidents not published because the publish queue was full.",
+	})
+	runDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "run_duration_seconds",
+		Help:    "Wall-clock duration of each ingestion run.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+)
+
+// startMetricsServer serves Prometheus metrics on addr in the background.
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		slog.Info("Serving metrics", "addr", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("Metrics server stopped", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Values of details.weather_provider, naming the service that supplied the weather.
+const (
+	providerNWS            = "nws"
+	providerOpenWeatherMap = "openweathermap"
+)
+
+// fallbackWeather is consulted when NWS reports a point outside its coverage. Nil, the
+// default, leaves such incidents without weather. Selected by FALLBACK_WEATHER.
+var fallbackWeather WeatherSource
+
+// newFallbackWeather parses FALLBACK_WEATHER: empty for none, or "openweathermap",
+// which needs an API key.
+func newFallbackWeather(spec, apiKey string) (WeatherSource, error) {
+	switch spec {
+	case "":
+		return nil, nil
+	case providerOpenWeatherMap:
+		if apiKey == "" {
+			return nil, errors.New("FALLBACK_WEATHER=openweathermap requires OPENWEATHERMAP_API_KEY")
+		}
+		return openWeatherMapSource{apiKey: apiKey}, nil
+	}
+	return nil, fmt.Errorf("unknown FALLBACK_WEATHER %q (want \"openweathermap\")", spec)
+}
+
+// getFallbackWeather looks up a point with fallbackWeather, applying the same
+// plausibility check as NWS results.
+func getFallbackWeather(ctx context.Context, lat, lon float64) (*WeatherData, error) {
+	weather, err := fallbackWeather.Weather(ctx, lat, lon, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemperature(weather); err != nil {
+		return nil, err
+	}
+	return weather, nil
+}
+
+// fallbackCovers reports whether a point outside the area NWS covers should be looked up
+// with fallbackWeather instead of going without weather: a fallback is configured, the
+// live NWS source is in use, and the point is a real coordinate rather than a missing
+// (0,0) or out-of-range one.
+func fallbackCovers(lat, lon float64) bool {
+	if fallbackWeather == nil {
+		return false
+	}
+	if _, live := weatherSource.(nwsWeatherSource); !live {
+		return false
+	}
+	if lat == 0 && lon == 0 {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// openWeatherMapURL is the OpenWeatherMap current-conditions endpoint.
+var openWeatherMapURL = "https://api.openweathermap.org/data/2.5/weather"
+
+// openWeatherMapResponse is the subset of the current-weather response that is used.
+type openWeatherMapResponse struct {
+	Dt   int64 `json:"dt"`
+	Main struct {
+		Temp float64 `json:"temp"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+		Deg   float64 `json:"deg"`
+	} `json:"wind"`
+	Weather []struct {
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+}
+
+// openWeatherMapSource fetches current conditions from OpenWeatherMap, which covers
+// points NWS doesn't, such as offshore. Like NWS, it only knows the present.
+type openWeatherMapSource struct {
+	apiKey string
+}
+
+func (s openWeatherMapSource) Weather(ctx context.Context, lat, lon float64, _ time.Time) (*WeatherData, error) {
+	ctx, cancel := context.WithTimeout(ctx, weatherDeadline)
+	defer cancel()
+
+	units := "imperial"
+	if weatherUnits == "si" {
+		units = "metric"
+	}
+	q := url.Values{
+		"lat":   {fmt.Sprint(lat)},
+		"lon":   {fmt.Sprint(lon)},
+		"units": {units},
+		"appid": {s.apiKey},
+	}
+	policy := retryPolicy{
+		name:          "OpenWeatherMap",
+		maxRetries:    weatherMaxRetries,
+		backoff:       weatherBackoffBase,
+		maxRetryAfter: nwsMaxRetryAfter,
+	}
+	var body []byte
+	err := withRetry(ctx, policy, func() error {
+		var err error
+		body, err = fetchOpenWeatherMapOnce(ctx, openWeatherMapURL+"?"+q.Encode())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp openWeatherMapResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OpenWeatherMap JSON: %w", err)
+	}
+	return resp.weatherData(), nil
+}
+
+// fetchOpenWeatherMapOnce performs a single GET. Network errors, 429s, and 5xx
+// responses are returned as a *retryableError. The URL carries the API key, so it is
+// left out of errors.
+func fetchOpenWeatherMapOnce(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, &retryableError{err: fmt.Errorf("failed to fetch OpenWeatherMap data: %w", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		err := fmt.Errorf("OpenWeatherMap API returned non-200 status: %s", resp.Status)
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return nil, &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		case resp.StatusCode >= 500:
+			return nil, &retryableError{err: err}
+		}
+		return nil, err
+	}
+	body, err := readLimited(resp.Body)
+	if errors.Is(err, errResponseTooLarge) {
+		return nil, fmt.Errorf("OpenWeatherMap response: %w", err)
+	}
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to read OpenWeatherMap response body: %w", err)}
+	}
+	return body, nil
+}
+
+// weatherHTTPClient is used for weather providers other than NWS.
+var weatherHTTPClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: outboundTransport,
+}
+
+// weatherData maps the response onto the NWS-shaped WeatherData, so the weather columns
+// read the same whichever provider filled them: wind speed as "10 mph" or "16 km/h", a
+// 16-point compass direction, and a capitalized short forecast.
+func (r openWeatherMapResponse) weatherData() *WeatherData {
+	w := &WeatherData{
+		Temperature:     int(math.Round(r.Main.Temp)),
+		TemperatureUnit: "F",
+		WindSpeed:       fmt.Sprintf("%d mph", int(math.Round(r.Wind.Speed))),
+		Provider:        providerOpenWeatherMap,
+	}
+	if weatherUnits == "si" {
+		// Metric wind speeds are in meters per second.
+		w.TemperatureUnit = "C"
+		w.WindSpeed = fmt.Sprintf("%d km/h", int(math.Round(r.Wind.Speed*3.6)))
+	}
+	if r.Wind.Speed > 0 {
+		w.WindDirection = compassPoint(r.Wind.Deg)
+	}
+	if len(r.Weather) > 0 {
+		w.ShortForecast = capitalizeWords(r.Weather[0].Description)
+		if r.Weather[0].Icon != "" {
+			w.Icon = "https://openweathermap.org/img/wn/" + r.Weather[0].Icon + "@2x.png"
+		}
+	}
+	if r.Dt > 0 {
+		w.StartTime = time.Unix(r.Dt, 0).UTC()
+	}
+	return w
+}
+
+// compassPoints are the 16 wind directions NWS reports, clockwise from north.
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// compassPoint converts a bearing in degrees to the nearest of compassPoints.
+func compassPoint(deg float64) string {
+	i := int(math.Round(math.Mod(deg, 360)/22.5)) % len(compassPoints)
+	if i < 0 {
+		i += len(compassPoints)
+	}
+	return compassPoints[i]
+}
+
+// capitalizeWords upper-cases the first letter of each word: "light rain" becomes
+// "Light Rain", matching NWS short forecasts. OpenWeatherMap localizes descriptions,
+// so the first letter may be multi-byte.
+func capitalizeWords(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCapitalizeWords(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"light rain", "Light Rain"},
+		{"  scattered   clouds ", "Scattered Clouds"},
+		{"éclaircies", "Éclaircies"},
+		{"überwiegend bewölkt", "Überwiegend Bewölkt"},
+		{"облачно с прояснениями", "Облачно С Прояснениями"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := capitalizeWords(tt.in); got != tt.want {
+			t.Errorf("capitalizeWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
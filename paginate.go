@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+)
+
+// feedPageSize, when positive, makes each feed request ask for that many incidents with
+// offset/limit query parameters, stepping the offset until a short page comes back.
+// Overridden by RWECC_PAGE_SIZE.
+var feedPageSize = 0
+
+// feedMaxPages caps how many pages of one feed are fetched per run, so a feed whose next
+// link never ends can't loop forever. Overridden by RWECC_MAX_PAGES.
+var feedMaxPages = 20
+
+// feedEnvelope is the paginated form of the feed: a page of incidents plus a link to the
+// next page, which is empty on the last one.
+type feedEnvelope struct {
+	Incidents []Incident `json:"incidents"`
+	Data      []Incident `json:"data"`
+	NextPage  string     `json:"nextPage"`
+	Next      string     `json:"next"`
+}
+
+// decodeFeedPage parses one page of a feed, which is either a bare JSON array of incidents
+// or a feedEnvelope, as it is read from r. A bare array is decoded one incident at a
+// time, so the raw payload is never held in memory alongside the decoded incidents. It
+// returns the page's incidents and its next-page link, if any.
+func decodeFeedPage(f feed, contentType string, r io.Reader) ([]Incident, string, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkJSONStart(contentType, first); err != nil {
+		prefix, _ := br.Peek(500)
+		slog.Error("Feed returned a non-JSON payload, skipping this run", "source", f.Source, "content_type", contentType, "body_prefix", string(prefix))
+		return nil, "", err
+	}
+
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, "", fmt.Errorf("unmarshalling JSON: %w", err)
+		}
+		var incidents []Incident
+		for dec.More() {
+			var incident Incident
+			if err := dec.Decode(&incident); err != nil {
+				return nil, "", fmt.Errorf("unmarshalling JSON incident %d: %w", len(incidents), err)
+			}
+			incidents = append(incidents, incident)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, "", fmt.Errorf("unmarshalling JSON: %w", err)
+		}
+		return incidents, "", nil
+	}
+	var env feedEnvelope
+	if err := dec.Decode(&env); err != nil {
+		return nil, "", fmt.Errorf("unmarshalling JSON: %w", err)
+	}
+	incidents := env.Incidents
+	if incidents == nil {
+		incidents = env.Data
+	}
+	next := env.NextPage
+	if next == "" {
+		next = env.Next
+	}
+	return incidents, next, nil
+}
+
+// firstNonSpace returns the first byte of r after any JSON whitespace, leaving it unread,
+// or zero if r is empty.
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+// firstPageURL adds offset/limit parameters for the first page when RWECC_PAGE_SIZE is set.
+func firstPageURL(feedURL string) string {
+	if feedPageSize <= 0 {
+		return feedURL
+	}
+	return withOffset(feedURL, 0)
+}
+
+// nextPageURL decides where the page after pageURL is. A next link from the envelope
+// wins, resolved against pageURL; otherwise, with offset paging, a full page means
+// there may be more. An empty result means this was the last page.
+func nextPageURL(feedURL, pageURL, next string, page, count int) string {
+	if next != "" {
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return ""
+		}
+		ref, err := url.Parse(next)
+		if err != nil {
+			slog.Warn("Ignoring unparseable next-page link", "next", next, "error", err)
+			return ""
+		}
+		return base.ResolveReference(ref).String()
+	}
+	if feedPageSize > 0 && count >= feedPageSize {
+		return withOffset(feedURL, page*feedPageSize)
+	}
+	return ""
+}
+
+// withOffset sets the offset and limit query parameters on feedURL.
+func withOffset(feedURL string, offset int) string {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return feedURL
+	}
+	q := u.Query()
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(feedPageSize))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
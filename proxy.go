@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// outboundTransport is shared by every outbound HTTP client. It honors HTTP_PROXY,
+// HTTPS_PROXY, and NO_PROXY unless PROXY_URL overrides it with an explicit proxy.
+var outboundTransport = newOutboundTransport()
+
+func newOutboundTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	return t
+}
+
+// setProxyURL routes all outbound requests through raw, which may be an http://,
+// https://, or socks5:// proxy URL.
+func setProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing PROXY_URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported PROXY_URL scheme %q (want http, https, or socks5)", u.Scheme)
+	}
+	outboundTransport.Proxy = http.ProxyURL(u)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestSetProxyURLRoutesRequests(t *testing.T) {
+	// The proxy answers NWS requests itself, so they can only succeed through it:
+	// nws.invalid never resolves.
+	var mu sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.URL.Host)
+		mu.Unlock()
+		if r.URL.Path == "/gridpoints/RAH/1,2/forecast/hourly" {
+			hourlyOK(w, r)
+			return
+		}
+		pointsOK(w, r, "http://nws.invalid/gridpoints/RAH/1,2/forecast/hourly")
+	}))
+	t.Cleanup(proxy.Close)
+
+	prevProxy := outboundTransport.Proxy
+	prevBase, prevCache, prevLimiter := nwsBaseURL, forecastCache, nwsLimiter
+	nwsBaseURL, forecastCache = "http://nws.invalid", newForecastURLCache(time.Hour)
+	nwsLimiter = rate.NewLimiter(rate.Inf, 0)
+	t.Cleanup(func() {
+		outboundTransport.Proxy = prevProxy
+		outboundTransport.CloseIdleConnections()
+		nwsBaseURL, forecastCache, nwsLimiter = prevBase, prevCache, prevLimiter
+	})
+
+	if err := setProxyURL(proxy.URL); err != nil {
+		t.Fatalf("setProxyURL: %v", err)
+	}
+	if _, err := getWeatherForIncident(context.Background(), 35.78, -78.64); err != nil {
+		t.Fatalf("getWeatherForIncident: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hosts) != 2 {
+		t.Fatalf("proxy saw %d requests, want the points and hourly requests", len(hosts))
+	}
+	for _, host := range hosts {
+		if host != "nws.invalid" {
+			t.Errorf("proxied request host = %q, want nws.invalid", host)
+		}
+	}
+}
+
+func TestSetProxyURLRejectsScheme(t *testing.T) {
+	prevProxy := outboundTransport.Proxy
+	t.Cleanup(func() { outboundTransport.Proxy = prevProxy })
+	if err := setProxyURL("ftp://proxy.example.com"); err == nil {
+		t.Error("setProxyURL accepted an ftp:// proxy")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// Publisher sends messages to a message bus topic (a NATS subject or a Kafka topic).
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg []byte) error
+	Close() error
+}
+
+var (
+	// publisher receives a copy of every committed incident. Nil disables publishing.
+	// Built from BROKER_URL.
+	publisher Publisher
+	// publishTopic is the subject or topic incidents are published to. Set by BROKER_TOPIC.
+	publishTopic = "incidents"
+)
+
+// publishTimeout bounds a single publish so a slow broker can't hold up the queue.
+const publishTimeout = 5 * time.Second
+
+// publishQueueSize is how many committed incidents may wait to be published. When the
+// broker falls this far behind, further incidents are dropped rather than blocking
+// ingestion.
+const publishQueueSize = 1000
+
+// publishMessage is a marshalled incident waiting in publishQueue.
+type publishMessage struct {
+	body          []byte
+	sourceID      string
+	correlationID string
+}
+
+var (
+	publishQueue chan publishMessage
+	publishWG    sync.WaitGroup
+	// publishCtx is cancelled when stopPublisher gives up draining, so the messages
+	// still queued fail fast instead of each waiting out publishTimeout.
+	publishCtx, cancelPublish = context.WithCancel(context.Background())
+)
+
+// startPublisher starts the background goroutine that sends queued incidents to
+// publisher. publishIncident only queues them, so a slow or unreachable broker never
+// holds up a commit.
+func startPublisher() {
+	publishQueue = make(chan publishMessage, publishQueueSize)
+	publishWG.Add(1)
+	go func() {
+		defer publishWG.Done()
+		for msg := range publishQueue {
+			ctx, cancel := context.WithTimeout(publishCtx, publishTimeout)
+			err := publisher.Publish(ctx, publishTopic, msg.body)
+			cancel()
+			if err != nil {
+				publishFailuresTotal.Inc()
+				slog.Warn("Publishing incident failed", "topic", publishTopic, "source_id", msg.sourceID, "correlation_id", msg.correlationID, "error", err)
+			}
+		}
+	}()
+}
+
+// stopPublisher publishes what is still queued, giving up after timeout, and closes
+// the publisher.
+func stopPublisher(timeout time.Duration) {
+	close(publishQueue)
+	done := make(chan struct{})
+	go func() {
+		publishWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Timed out draining the publish queue, dropping the rest", "queued", len(publishQueue))
+		cancelPublish()
+		<-done
+	}
+	if err := publisher.Close(); err != nil {
+		slog.Warn("Error closing message bus publisher", "error", err)
+	}
+}
+
+// newPublisher builds a Publisher from a broker URL: nats://host:4222 for NATS, or
+// kafka://broker1:9092,broker2:9092 for Kafka.
+func newPublisher(brokerURL string) (Publisher, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing broker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		nc, err := nats.Connect(brokerURL, nats.Name(rweccUserAgent))
+		if err != nil {
+			return nil, fmt.Errorf("connecting to NATS: %w", err)
+		}
+		return natsPublisher{nc}, nil
+	case "kafka":
+		return kafkaPublisher{&kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(u.Host, ",")...),
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireOne,
+			BatchTimeout: 10 * time.Millisecond,
+		}}, nil
+	}
+	return nil, fmt.Errorf("unsupported broker scheme %q (want nats:// or kafka://)", u.Scheme)
+}
+
+type natsPublisher struct{ nc *nats.Conn }
+
+func (p natsPublisher) Publish(ctx context.Context, subject string, msg []byte) error {
+	return p.nc.Publish(subject, msg)
+}
+
+func (p natsPublisher) Close() error {
+	return p.nc.Drain()
+}
+
+type kafkaPublisher struct{ w *kafka.Writer }
+
+func (p kafkaPublisher) Publish(ctx context.Context, topic string, msg []byte) error {
+	return p.w.WriteMessages(ctx, kafka.Message{Topic: topic, Value: msg})
+}
+
+func (p kafkaPublisher) Close() error {
+	return p.w.Close()
+}
+
+// publishedIncident is the JSON message published for a committed incident.
+type publishedIncident struct {
+	Source       string       `json:"source"`
+	SourceID     string       `json:"source_id"`
+	EventType    string       `json:"event_type"`
+	Severity     string       `json:"severity"`
+	Address      string       `json:"address"`
+	Jurisdiction string       `json:"jurisdiction"`
+	Problem      string       `json:"problem"`
+	Timestamp    string       `json:"timestamp"`
+	Latitude     float64      `json:"latitude"`
+	Longitude    float64      `json:"longitude"`
+	Weather      *WeatherData `json:"weather"`
+	Alerts       []NWSAlert   `json:"alerts,omitempty"`
+}
+
+// publishIncident queues a committed incident for publishing to publishTopic. It never
+// blocks: if the queue is full the incident is dropped and counted. Failures are logged
+// only; the row is already committed and ingestion carries on.
+func publishIncident(row enrichedIncident) {
+	if publisher == nil {
+		return
+	}
+	incident := row.incident
+	msg, err := json.Marshal(publishedIncident{
+		Source:       incident.Source,
+		SourceID:     sourceIDFor(incident),
+		EventType:    classifyEventType(incident.Problem),
+		Severity:     classifySeverity(incident.Problem),
+		Address:      incident.Address,
+		Jurisdiction: incident.Jurisdiction,
+		Problem:      incident.Problem,
+		Timestamp:    incident.Timestamp,
+		Latitude:     incident.Lat,
+		Longitude:    incident.Long,
+		Weather:      row.weather,
+		Alerts:       row.alerts,
+	})
+	if err != nil {
+		slog.Warn("Could not marshal incident for publishing", "source_id", sourceIDFor(incident), "correlation_id", correlationID(incident), "error", err)
+		return
+	}
+	select {
+	case publishQueue <- publishMessage{body: msg, sourceID: sourceIDFor(incident), correlationID: correlationID(incident)}:
+	default:
+		publishDroppedTotal.Inc()
+		slog.Debug("Publish queue full, dropping incident", "topic", publishTopic, "source_id", sourceIDFor(incident), "correlation_id", correlationID(incident))
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakePublisher records published messages, holding each Publish until release closes.
+type fakePublisher struct {
+	release chan struct{}
+	mu      sync.Mutex
+	got     int
+	closed  bool
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, topic string, msg []byte) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.mu.Lock()
+	p.got++
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestPublishIncidentDoesNotBlock(t *testing.T) {
+	fake := &fakePublisher{release: make(chan struct{})}
+	prev := publisher
+	publisher = fake
+	t.Cleanup(func() { publisher = prev })
+	startPublisher()
+
+	// With the broker stuck, a full queue's worth plus a few more must still return
+	// at once, dropping the overflow.
+	const total = publishQueueSize + 5
+	droppedBefore := testutil.ToFloat64(publishDroppedTotal)
+	row := enrichedIncident{incident: Incident{Source: "RWECC", Problem: "MVC", Address: "1 Main St", Timestamp: "2024-05-01 10:00:00"}}
+	start := time.Now()
+	for range total {
+		publishIncident(row)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("publishing %d incidents took %s with the broker stuck, want it not to block", total, elapsed)
+	}
+	dropped := int(testutil.ToFloat64(publishDroppedTotal) - droppedBefore)
+	if dropped < 4 {
+		t.Errorf("dropped %d incidents, want at least the overflow beyond the queue and the one in flight", dropped)
+	}
+
+	close(fake.release)
+	stopPublisher(10 * time.Second)
+	if fake.got+dropped != total {
+		t.Errorf("published %d and dropped %d, want every one of %d accounted for", fake.got, dropped, total)
+	}
+	if !fake.closed {
+		t.Error("stopPublisher did not close the publisher")
+	}
+}
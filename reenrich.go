@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// missingWeatherSQL selects a source's stored incidents that have no weather, newest
+// first, optionally only those since $2.
+const missingWeatherSQL = `
+	SELECT id, details->'raw_incident' FROM unified_incidents
+	WHERE source = $1 AND weather_temp IS NULL AND details ? 'raw_incident'
+		AND ($2::timestamptz IS NULL OR timestamp >= $2::timestamptz)
+	ORDER BY timestamp DESC;
+`
+
+// reenrichSQL rewrites just the weather columns and details of one stored incident.
+const reenrichSQL = `
+	UPDATE unified_incidents SET
+		details = $2,
+		weather_temp = $3,
+		weather_wind_speed = $4,
+		weather_forecast = $5,
+		weather_icon = $6,
+		weather_observed_at = $7,
+		weather_status = $8,
+		weather_wind_dir = $9
+	WHERE id = $1;
+`
+
+// reenrichColumns are the upsertColumns values reenrichSQL takes, in its parameter order.
+var reenrichColumns = []string{
+	"details", "weather_temp", "weather_wind_speed", "weather_forecast", "weather_icon",
+	"weather_observed_at", "weather_status", "weather_wind_dir",
+}
+
+// storedIncident is a row awaiting re-enrichment.
+type storedIncident struct {
+	id       int64
+	incident Incident
+}
+
+// reenrichIncidents backfills weather for every configured feed's stored incidents that
+// have none, such as those saved during an NWS outage, without re-ingesting the feed.
+// Lookups go through weatherSource, so the NWS rate limiter applies. A zero since
+// considers every stored incident.
+func reenrichIncidents(ctx context.Context, db *sql.DB, since time.Duration) error {
+	var sinceTime sql.NullTime
+	if since > 0 {
+		sinceTime = sql.NullTime{Time: time.Now().Add(-since), Valid: true}
+	}
+
+	var stmt *sql.Stmt
+	if !dryRun {
+		var err error
+		stmt, err = db.PrepareContext(ctx, reenrichSQL)
+		if err != nil {
+			return fmt.Errorf("preparing re-enrich statement: %w", err)
+		}
+		defer stmt.Close()
+	}
+
+	var found, enriched int
+	for _, f := range feeds {
+		rows, err := loadMissingWeather(ctx, db, f.Source, sinceTime)
+		if err != nil {
+			return err
+		}
+		found += len(rows)
+		for _, r := range rows {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result := enrichIncident(ctx, r.incident)
+			if result.weather == nil {
+				continue
+			}
+			if err := updateWeather(ctx, stmt, r.id, result); err != nil {
+				slog.Error("Error saving re-enriched weather", "id", r.id, "source_id", sourceIDFor(r.incident), "error", err)
+				continue
+			}
+			enriched++
+		}
+	}
+	slog.Info("Re-enrichment complete", "incidents_missing_weather", found, "enriched", enriched, "still_missing", found-enriched)
+	return nil
+}
+
+// loadMissingWeather reads source's stored incidents without weather.
+func loadMissingWeather(ctx context.Context, db *sql.DB, source string, since sql.NullTime) ([]storedIncident, error) {
+	rows, err := db.QueryContext(ctx, missingWeatherSQL, source, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying incidents missing weather: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []storedIncident
+	for rows.Next() {
+		var r storedIncident
+		var raw []byte
+		if err := rows.Scan(&r.id, &raw); err != nil {
+			return nil, fmt.Errorf("scanning incident missing weather: %w", err)
+		}
+		if err := json.Unmarshal(raw, &r.incident); err != nil {
+			slog.Warn("Skipping stored incident with unreadable raw_incident", "id", r.id, "error", err)
+			continue
+		}
+		// Source isn't part of the stored JSON.
+		r.incident.Source = source
+		incidents = append(incidents, r)
+	}
+	return incidents, rows.Err()
+}
+
+// updateWeather writes result's weather columns and details to the row with id.
+func updateWeather(ctx context.Context, stmt *sql.Stmt, id int64, result enrichedIncident) error {
+	args, err := buildUnifiedRow(result)
+	if err != nil {
+		return err
+	}
+	byColumn := make(map[string]any, len(upsertColumns))
+	for i, col := range upsertColumns {
+		byColumn[col] = args[i]
+	}
+	params := []any{id}
+	for _, col := range reenrichColumns {
+		params = append(params, byColumn[col])
+	}
+	if dryRun {
+		slog.Info("Dry run: would update weather", "id", id, "source_id", sourceIDFor(result.incident), "weather_temp", byColumn["weather_temp"], "weather_forecast", byColumn["weather_forecast"])
+		return nil
+	}
+	_, err = stmt.ExecContext(ctx, params...)
+	return err
+}
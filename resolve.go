@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// resolveAfter is how many consecutive runs an active incident may be missing from the
+// feed before it is marked resolved. Overridden by RESOLVE_AFTER.
+var resolveAfter = 2
+
+// resolveOnEmptyFeed lets a payload with no incidents count as every active incident
+// missing. Off by default, since an empty payload is usually a feed problem. Overridden
+// by RESOLVE_ON_EMPTY_FEED.
+var resolveOnEmptyFeed = false
+
+// resolveMissingSQL bumps missed_runs for rows with status $4 (active) absent from this
+// run's payload and sets those that have now been missing for $3 runs to $5 (resolved),
+// returning how many it resolved.
+const resolveMissingSQL = `
+	WITH updated AS (
+		UPDATE unified_incidents SET
+			missed_runs = COALESCE(missed_runs, 0) + 1,
+			status = CASE WHEN COALESCE(missed_runs, 0) + 1 >= $3 THEN $5 ELSE status END
+		WHERE source = $1 AND status = $4 AND NOT (source_id = ANY($2))
+		RETURNING status
+	)
+	SELECT count(*) FROM updated WHERE status = $5;
+`
+
+// resetSeenSQL clears missed_runs for rows present in this run's payload. Rows rewritten by
+// the upsert are already reset; this covers unchanged rows the upsert skipped.
+const resetSeenSQL = `
+	UPDATE unified_incidents SET missed_runs = 0
+	WHERE source = $1 AND missed_runs > 0 AND source_id = ANY($2);
+`
+
+// resolveMissingIncidents marks active incidents that have fallen off the feed as resolved
+// once they have been missing for resolveAfter runs. seen holds this run's source_ids.
+func resolveMissingIncidents(ctx context.Context, db *sql.DB, source string, seen []string) (int, error) {
+	if _, err := db.ExecContext(ctx, resetSeenSQL, source, pq.Array(seen)); err != nil {
+		return 0, fmt.Errorf("resetting missed runs: %w", err)
+	}
+	var resolved int
+	if err := db.QueryRowContext(ctx, resolveMissingSQL, source, pq.Array(seen), resolveAfter, statusActive, statusResolved).Scan(&resolved); err != nil {
+		return 0, fmt.Errorf("resolving missing incidents: %w", err)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryableError marks a failure worth retrying. retryAfter, when set, is the delay the
+// server asked for via a Retry-After header.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// parseRetryAfter interprets a Retry-After header given either as delay-seconds or as
+// an HTTP-date. It returns zero if the header is absent or unparseable.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryPolicy configures withRetry for one kind of upstream request.
+type retryPolicy struct {
+	// name identifies the request in logs and errors, e.g. "NWS points".
+	name       string
+	maxRetries int
+	// backoff is the delay before the first retry; it doubles on each attempt.
+	backoff time.Duration
+	// maxRetryAfter caps how long a server's Retry-After is honored.
+	maxRetryAfter time.Duration
+}
+
+// withRetry runs op, retrying failures it marks as *retryableError with exponential
+// backoff until p.maxRetries or ctx's deadline is exhausted. Other errors are returned
+// immediately. Each retry is logged.
+func withRetry(ctx context.Context, p retryPolicy, op func() error) error {
+	backoff := p.backoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		var retryErr *retryableError
+		if err == nil || !errors.As(err, &retryErr) || attempt >= p.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		wait := backoff
+		if retryErr.retryAfter > 0 {
+			wait = min(max(retryErr.retryAfter, backoff), p.maxRetryAfter)
+			slog.WarnContext(ctx, "Throttled by upstream, honoring Retry-After", "request", p.name, "retry_after", retryErr.retryAfter, "wait", wait)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("giving up on %s request after %d attempts: %w", p.name, attempt+1, err)
+		}
+		slog.WarnContext(ctx, "Retrying request", "request", p.name, "backoff", wait, "retry", attempt+1, "max_retries", p.maxRetries, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%s request cancelled: %w", p.name, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// schemaSQL creates unified_incidents and adds any columns it is missing.
+//
+//go:embed schema.sql
+var schemaSQL string
+
+// runMigrations applies schemaSQL. It is safe to run repeatedly.
+func runMigrations(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("applying schema.sql: %w", err)
+	}
+	return nil
+}
+
+// unifiedColumns lists every unified_incidents column the ingestor reads or writes.
+// Keep it in sync with upsertSQL, schema.sql, and the other queries against the table.
+var unifiedColumns = []string{
+	"source", "source_id", "event_type", "status", "address", "latitude", "longitude",
+	"timestamp", "details", "jurisdiction", "problem_detail", "weather_temp",
+	"weather_wind_speed", "weather_forecast", "weather_icon", "weather_observed_at", "content_hash", "missed_runs",
+	"severity", "weather_status", "weather_wind_dir",
+}
+
+// missingColumns returns the columns in unifiedColumns that unified_incidents lacks.
+// A missing table reports every column as missing.
+func missingColumns(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'unified_incidents'`)
+	if err != nil {
+		return nil, fmt.Errorf("querying information_schema: %w", err)
+	}
+	defer rows.Close()
+
+	present := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning column name: %w", err)
+		}
+		present[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, col := range unifiedColumns {
+		if !present[col] {
+			missing = append(missing, col)
+		}
+	}
+	return missing, nil
+}
+
+// conflictConstraintSQL reports whether unified_incidents has a unique index on exactly
+// (source, source_id), which the upsert's ON CONFLICT clause needs. Tables created by
+// schema.sql have one, but older hand-made tables may not.
+const conflictConstraintSQL = `
+	SELECT EXISTS (
+		SELECT 1 FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relname = 'unified_incidents'
+			AND i.indisunique AND i.indpred IS NULL AND i.indnkeyatts = 2
+			AND (SELECT array_agg(a.attname::text ORDER BY a.attname) FROM pg_attribute a
+				WHERE a.attrelid = c.oid AND a.attnum = ANY(i.indkey)) = ARRAY['source', 'source_id']
+	);
+`
+
+// addConflictConstraintSQL adds the constraint schema.sql declares. It fails if duplicate
+// (source, source_id) rows already exist; those must be removed first.
+const addConflictConstraintSQL = `
+	ALTER TABLE unified_incidents ADD CONSTRAINT unified_incidents_source_source_id_key UNIQUE (source, source_id);
+`
+
+// errMissingConflictConstraint explains the upsert's cryptic failure when the constraint
+// is missing.
+var errMissingConflictConstraint = errors.New("unified_incidents has no unique constraint on (source, source_id), which the upsert's ON CONFLICT needs; " +
+	"create it with `" + strings.TrimSpace(addConflictConstraintSQL) + "` or set RUN_MIGRATIONS=true")
+
+// hasConflictConstraint reports whether unified_incidents has the upsert's constraint.
+func hasConflictConstraint(ctx context.Context, db *sql.DB) (bool, error) {
+	var ok bool
+	if err := db.QueryRowContext(ctx, conflictConstraintSQL).Scan(&ok); err != nil {
+		return false, fmt.Errorf("checking for the (source, source_id) constraint: %w", err)
+	}
+	return ok, nil
+}
+
+// explainUpsertError wraps Postgres's "no unique or exclusion constraint matching the ON
+// CONFLICT specification" (42P10) in errMissingConflictConstraint, so the operator is
+// told how to fix it. Other errors are returned unchanged.
+func explainUpsertError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P10" {
+		return fmt.Errorf("%w: %w", errMissingConflictConstraint, err)
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+-- Schema for the unified incidents table. Every statement is idempotent so this can
+-- run on each startup with RUN_MIGRATIONS=true, including against older tables that
+-- predate some of the columns.
+CREATE TABLE IF NOT EXISTS unified_incidents (
+	id             BIGSERIAL PRIMARY KEY,
+	source         TEXT NOT NULL,
+	source_id      TEXT NOT NULL,
+	event_type     TEXT,
+	status         TEXT NOT NULL DEFAULT 'active',
+	address        TEXT,
+	latitude       DOUBLE PRECISION,
+	longitude      DOUBLE PRECISION,
+	timestamp      TIMESTAMPTZ,
+	details        JSONB,
+	CONSTRAINT unified_incidents_source_source_id_key UNIQUE (source, source_id)
+);
+
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS jurisdiction TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS problem_detail TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_temp INTEGER;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_wind_speed TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_forecast TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_icon TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_observed_at TIMESTAMPTZ;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS content_hash TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS missed_runs INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS severity TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_status TEXT;
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS weather_wind_dir TEXT;
+
+CREATE INDEX IF NOT EXISTS unified_incidents_source_status_idx ON unified_incidents (source, status);
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// seenCache remembers the content hash of recently processed incidents, so a daemon
+// polling faster than incidents change can skip them without the database hash check.
+// It evicts the least recently used entry beyond size and ignores entries older than
+// ttl. Safe for concurrent use.
+type seenCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // of *seenEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type seenEntry struct {
+	key       string
+	hash      string
+	expiresAt time.Time
+}
+
+// seenIncidents is the cache used by processFeed. A nil cache, the default when
+// SEEN_CACHE_SIZE is zero, remembers nothing.
+var seenIncidents *seenCache
+
+func newSeenCache(size int, ttl time.Duration) *seenCache {
+	return &seenCache{size: size, ttl: ttl, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// seenKey identifies an incident across feeds.
+func seenKey(incident Incident) string {
+	return incidentSource(incident) + "\x00" + sourceIDFor(incident)
+}
+
+// Seen reports whether incident was processed within the TTL with the same content.
+func (c *seenCache) Seen(incident Incident) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[seenKey(incident)]
+	if !ok {
+		return false
+	}
+	entry := el.Value.(*seenEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, entry.key)
+		return false
+	}
+	c.order.MoveToFront(el)
+	return entry.hash == contentHash(incident)
+}
+
+// Add records incident as processed now.
+func (c *seenCache) Add(incident Incident) {
+	if c == nil {
+		return
+	}
+	key, hash := seenKey(incident), contentHash(incident)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*seenEntry)
+		entry.hash, entry.expiresAt = hash, time.Now().Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&seenEntry{key: key, hash: hash, expiresAt: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*seenEntry).key)
+	}
+}
+
+// skipSeen drops incidents the seen cache says were just processed unchanged. It
+// returns the rest and how many were skipped.
+func skipSeen(incidents []Incident) ([]Incident, int) {
+	if seenIncidents == nil {
+		return incidents, 0
+	}
+	fresh := incidents[:0:0]
+	for _, incident := range incidents {
+		if !seenIncidents.Seen(incident) {
+			fresh = append(fresh, incident)
+		}
+	}
+	return fresh, len(incidents) - len(fresh)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// selftestPoint is a known-covered location (downtown Raleigh) used for the NWS check.
+var selftestPoint = [2]float64{35.7796, -78.6382}
+
+// runSelfTest checks that the database, every configured feed, and NWS are reachable,
+// printing a PASS or FAIL line for each. Nothing is written. It reports whether every
+// check passed.
+func runSelfTest(ctx context.Context, db *sql.DB) bool {
+	ok := true
+	report := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(os.Stdout, "FAIL  %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(os.Stdout, "PASS  %s\n", name)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	report("database", db.PingContext(pingCtx))
+	cancel()
+
+	for _, f := range feeds {
+		if f.Path != "" {
+			_, err := readPayloadFile(f.Path)
+			report(fmt.Sprintf("feed %s (%s)", f.Source, f.Path), err)
+			continue
+		}
+		report(fmt.Sprintf("feed %s (%s)", f.Source, f.URL), checkFeedReachable(ctx, f))
+	}
+
+	lat, lon := selftestPoint[0], selftestPoint[1]
+	_, err := fetchNWS(ctx, nwsClient, nwsURL("points/"+coordKey(lat, lon)), "points")
+	report("NWS points lookup", err)
+
+	return ok
+}
+
+// checkFeedReachable GETs a feed's first page, with the configured credentials, and
+// checks that it answers with JSON.
+func checkFeedReachable(ctx context.Context, f feed) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", firstPageURL(f.URL), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", rweccUserAgent)
+	rweccAuth.apply(req)
+	resp, err := rweccClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("status %s (check RWECC_AUTH_BEARER or RWECC_AUTH_BASIC_USER/PASS)", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return err
+	}
+	return checkJSONPayload(resp.Header.Get("Content-Type"), body)
+}
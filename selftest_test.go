@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckFeedReachable(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    feedAuth
+		wantErr string
+	}{
+		{name: "bearer", auth: feedAuth{Bearer: "s3cret"}},
+		{name: "basic", auth: feedAuth{BasicUser: "rwecc", BasicPass: "hunter2"}},
+		{name: "no credentials", wantErr: "RWECC_AUTH_"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, basic := r.BasicAuth()
+		if r.Header.Get("Authorization") != "Bearer s3cret" && !(basic && user == "rwecc" && pass == "hunter2") {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+	t.Cleanup(srv.Close)
+
+	prevAuth := rweccAuth
+	t.Cleanup(func() { rweccAuth = prevAuth })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rweccAuth = tt.auth
+			err := checkFeedReachable(context.Background(), feed{Source: sourceName, URL: srv.URL})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkFeedReachable: %v", err)
+			}
+		})
+	}
+}
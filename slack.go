@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values of NOTIFY_FORMAT.
+const (
+	// notifyJSON posts webhookPayload and clusterPayload as-is, for Discord and custom
+	// consumers.
+	notifyJSON = "json"
+	// notifySlack posts Slack Block Kit messages.
+	notifySlack = "slack"
+)
+
+// notifyFormat is the shape of webhook bodies. Overridden by NOTIFY_FORMAT.
+var notifyFormat = notifyJSON
+
+// slackMessage is a Slack incoming-webhook body. Text is the fallback shown in
+// notifications; Blocks is the rendered message.
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackHeaderMax is Slack's limit on header text.
+const slackHeaderMax = 150
+
+// slackEscape escapes the characters Slack's mrkdwn treats as control sequences.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func slackHeader(text string) slackBlock {
+	if r := []rune(text); len(r) > slackHeaderMax {
+		text = string(r[:slackHeaderMax-1]) + "…"
+	}
+	return slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: text}}
+}
+
+func slackField(label, value string) slackText {
+	return slackText{Type: "mrkdwn", Text: "*" + label + "*\n" + slackEscape(value)}
+}
+
+// mapsLink is a Google Maps search URL for a point.
+func mapsLink(lat, long float64) string {
+	return fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", lat, long)
+}
+
+// slackIncidentMessage formats a new incident: a header, the address, problem,
+// jurisdiction, and time as fields, a map link, and the weather as context.
+func slackIncidentMessage(incident Incident, weather *WeatherData) slackMessage {
+	blocks := []slackBlock{
+		slackHeader("New incident: " + incident.Problem),
+		{Type: "section", Fields: []slackText{
+			slackField("Address", incident.Address),
+			slackField("Problem", incident.Problem),
+			slackField("Jurisdiction", incident.Jurisdiction),
+			slackField("Time", incident.Timestamp),
+		}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "<" + mapsLink(incident.Lat, incident.Long) + "|Open in Google Maps>"}},
+	}
+	if weather != nil {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: slackEscape(weatherSummary(weather))}}})
+	}
+	return slackMessage{
+		Text:   fmt.Sprintf("New incident: %s at %s", incident.Problem, incident.Address),
+		Blocks: blocks,
+	}
+}
+
+// slackClusterMessage formats a cluster alert with its incidents listed.
+func slackClusterMessage(cluster clusterPayload) slackMessage {
+	var list strings.Builder
+	for _, incident := range cluster.Incidents {
+		fmt.Fprintf(&list, "• %s, %s (%s)\n", slackEscape(incident.Problem), slackEscape(incident.Address), slackEscape(incident.Timestamp))
+	}
+	summary := fmt.Sprintf("%d incidents near %.4f, %.4f", cluster.Count, cluster.Latitude, cluster.Longitude)
+	return slackMessage{
+		Text: "Incident cluster: " + summary,
+		Blocks: []slackBlock{
+			slackHeader("Incident cluster: " + summary),
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: list.String()}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "<" + mapsLink(cluster.Latitude, cluster.Longitude) + "|Open in Google Maps>"}},
+			{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: fmt.Sprintf("Between %s and %s UTC", cluster.WindowStart.Format("15:04"), cluster.WindowEnd.Format("15:04"))}}},
+		},
+	}
+}
+
+// weatherSummary renders weather as one line, such as "72°F, wind 5 mph NW, Sunny".
+func weatherSummary(weather *WeatherData) string {
+	parts := []string{fmt.Sprintf("%d°%s", weather.Temperature, weather.TemperatureUnit)}
+	if wind := strings.TrimSpace(weather.WindSpeed + " " + weather.WindDirection); wind != "" {
+		parts = append(parts, "wind "+wind)
+	}
+	if weather.ShortForecast != "" {
+		parts = append(parts, weather.ShortForecast)
+	}
+	return strings.Join(parts, ", ")
+}
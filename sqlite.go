@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	_ "modernc.org/sqlite"
+)
+
+// Values of DB_DRIVER.
+const (
+	driverPostgres = "postgres"
+	// driverSQLite writes to a local file named by DATABASE_NAME, for development without
+	// a Postgres server. Postgres-only features (the audit table, incident history,
+	// resolving missing incidents, skipping unchanged ones, advisory locks) are off.
+	driverSQLite = "sqlite"
+)
+
+// dbDriver is the database unified_incidents lives in. Set by DB_DRIVER.
+var dbDriver = driverPostgres
+
+// createSQLiteSchemaSQL is schema.sql in SQLite's dialect: JSON is stored as TEXT and
+// timestamps as ISO 8601 text.
+const createSQLiteSchemaSQL = `
+	CREATE TABLE IF NOT EXISTS unified_incidents (
+		id                   INTEGER PRIMARY KEY,
+		source               TEXT NOT NULL,
+		source_id            TEXT NOT NULL,
+		event_type           TEXT,
+		status               TEXT NOT NULL DEFAULT 'active',
+		address              TEXT,
+		latitude             REAL,
+		longitude            REAL,
+		timestamp            TEXT,
+		details              TEXT,
+		jurisdiction         TEXT,
+		problem_detail       TEXT,
+		weather_temp         INTEGER,
+		weather_wind_speed   TEXT,
+		weather_forecast     TEXT,
+		weather_icon         TEXT,
+		weather_observed_at  TEXT,
+		content_hash         TEXT,
+		missed_runs          INTEGER NOT NULL DEFAULT 0,
+		severity             TEXT,
+		weather_status       TEXT,
+		weather_wind_dir     TEXT,
+		UNIQUE (source, source_id)
+	);
+`
+
+// ensureSQLiteSchema creates unified_incidents in a SQLite database.
+func ensureSQLiteSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, createSQLiteSchemaSQL); err != nil {
+		return fmt.Errorf("creating SQLite unified_incidents table: %w", err)
+	}
+	return nil
+}
+
+// postgresPlaceholder matches a Postgres positional parameter such as $3.
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// rebindSQLite rewrites Postgres $N placeholders as SQLite's equivalent ?N.
+func rebindSQLite(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+// sqliteExistsSQL reports whether an incident is already stored. SQLite has no xmax, so
+// the upsert can't say whether it inserted.
+const sqliteExistsSQL = `SELECT 1 FROM unified_incidents WHERE source = ?1 AND source_id = ?2`
+
+// sqliteStore upserts incidents into a SQLite unified_incidents, committing every size
+// rows and on Flush like postgresStore. A failed statement doesn't abort a SQLite
+// transaction, so a bad row is reported and skipped without replaying the batch.
+type sqliteStore struct {
+	db   *sql.DB
+	size int
+
+	tx         *sql.Tx
+	existsStmt *sql.Stmt
+	upsertStmt *sql.Stmt
+	pending    []pendingRow
+	saved      int
+}
+
+func newSQLiteStore(db *sql.DB, size int) *sqliteStore {
+	return &sqliteStore{db: db, size: size}
+}
+
+func (s *sqliteStore) begin(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	existsStmt, err := tx.PrepareContext(ctx, sqliteExistsSQL)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("preparing exists statement: %w", err)
+	}
+	upsertStmt, err := tx.PrepareContext(ctx, rebindSQLite(insertSQL+conflictSQL()))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("preparing upsert statement: %w", err)
+	}
+	s.tx, s.existsStmt, s.upsertStmt, s.pending = tx, existsStmt, upsertStmt, nil
+	return nil
+}
+
+// Save upserts one incident, opening a transaction if none is open. In dry-run mode rows
+// are only logged and counted.
+func (s *sqliteStore) Save(ctx context.Context, row enrichedIncident) error {
+	args, err := buildUnifiedRow(row)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		logDryRunRow(args)
+		s.saved++
+		return nil
+	}
+	if s.tx == nil {
+		if err := s.begin(ctx); err != nil {
+			return err
+		}
+	}
+	// The details JSON is bound as text so SQLite's JSON functions can read it.
+	args = detailsAsText(args)
+
+	var one int
+	err = s.existsStmt.QueryRowContext(ctx, args[0], args[1]).Scan(&one)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		dbErrorsTotal.Inc()
+		return fmt.Errorf("checking for stored incident: %w", err)
+	}
+	inserted := errors.Is(err, sql.ErrNoRows)
+	if _, err := s.upsertStmt.ExecContext(ctx, args...); err != nil {
+		dbErrorsTotal.Inc()
+		return err
+	}
+
+	s.pending = append(s.pending, pendingRow{row, inserted})
+	if s.size > 0 && len(s.pending) >= s.size {
+		return s.commit()
+	}
+	return nil
+}
+
+// commit commits the open transaction. The next Save opens a new one.
+func (s *sqliteStore) commit() error {
+	tx, rows := s.tx, s.pending
+	s.tx, s.existsStmt, s.upsertStmt, s.pending = nil, nil, nil, nil
+	if err := tx.Commit(); err != nil {
+		dbErrorsTotal.Inc()
+		return fmt.Errorf("committing batch: %w", err)
+	}
+	s.saved += len(rows)
+	announceCommitted(rows)
+	return nil
+}
+
+// Flush commits any open transaction and returns the rows committed since the last Flush.
+func (s *sqliteStore) Flush(ctx context.Context) (int, error) {
+	var err error
+	if s.tx != nil {
+		err = s.commit()
+	}
+	saved := s.saved
+	s.saved = 0
+	return saved, err
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// IncidentStore persists enriched incidents. Implementations may buffer writes; Flush
+// makes them durable and reports how many incidents were committed since the last Flush.
+type IncidentStore interface {
+	Save(ctx context.Context, row enrichedIncident) error
+	Flush(ctx context.Context) (int, error)
+}
+
+// batchSize is how many rows are written per transaction before committing. Zero means
+// each Flush commits everything saved since the previous one. Overridden by BATCH_SIZE.
+var batchSize = 0
+
+const (
+	// upsertMaxAttempts bounds how many times one incident's upsert is tried.
+	upsertMaxAttempts = 3
+	// upsertRetryDelay is multiplied by the attempt number between upsert retries.
+	upsertRetryDelay = 250 * time.Millisecond
+)
+
+// isRetryableDBError reports whether err is a transient database failure worth retrying:
+// serialization failures and deadlocks (class 40), connection exceptions (class 08),
+// an administrator shutdown (class 57), or a dropped connection. Constraint violations
+// and other data errors are permanent.
+func isRetryableDBError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "40", "08", "57":
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// postgresStore upserts incidents into unified_incidents inside a transaction using a
+// prepared statement, committing every size rows and on Flush.
+type postgresStore struct {
+	db   *sql.DB
+	size int
+	// secondary marks a copy of the data, such as the analytics replica: its commits
+	// don't count toward metrics, send notifications, or write incident history.
+	secondary bool
+
+	tx          *sql.Tx
+	stmt        *sql.Stmt
+	historyStmt *sql.Stmt
+	pending     []pendingRow
+	saved       int
+}
+
+// pendingRow is a row written to the open transaction but not yet committed.
+type pendingRow struct {
+	enrichedIncident
+	inserted bool
+}
+
+func newPostgresStore(db *sql.DB, size int) *postgresStore {
+	return &postgresStore{db: db, size: size}
+}
+
+// newSecondaryPostgresStore is a postgresStore for a copy of the data; see secondary.
+func newSecondaryPostgresStore(db *sql.DB, size int) *postgresStore {
+	return &postgresStore{db: db, size: size, secondary: true}
+}
+
+func (s *postgresStore) begin(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, upsertSQL())
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("preparing upsert statement: %w", err)
+	}
+	// Skipped conflicts never change a row, so there is no history to record.
+	var historyStmt *sql.Stmt
+	if incidentHistory && !s.secondary && conflictMode == conflictUpdate {
+		historyStmt, err = tx.PrepareContext(ctx, recordHistorySQL)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("preparing history statement: %w", err)
+		}
+	}
+	s.tx, s.stmt, s.historyStmt, s.pending = tx, stmt, historyStmt, nil
+	return nil
+}
+
+// write upserts one incident into the open transaction, first recording its previous
+// version when history is enabled.
+func (s *postgresStore) write(ctx context.Context, row enrichedIncident) (bool, error) {
+	if s.historyStmt != nil {
+		if err := recordIncidentHistory(ctx, s.historyStmt, row.incident); err != nil {
+			return false, err
+		}
+	}
+	return saveToUnifiedDB(ctx, s.stmt, row)
+}
+
+// rowSavepoint wraps each row's writes so a failing row can be undone on its own.
+const rowSavepoint = "incident_row"
+
+// writeAtSavepoint writes one incident under rowSavepoint. On failure the transaction is
+// rolled back to the savepoint, undoing only this row; lost reports that the rollback
+// failed too, meaning the transaction itself is gone (e.g. the connection dropped).
+func (s *postgresStore) writeAtSavepoint(ctx context.Context, row enrichedIncident) (inserted, lost bool, err error) {
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+rowSavepoint); err != nil {
+		return false, true, fmt.Errorf("creating savepoint: %w", err)
+	}
+	inserted, err = s.write(ctx, row)
+	if err == nil {
+		_, err = s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+rowSavepoint)
+	}
+	if err == nil {
+		return inserted, false, nil
+	}
+	if _, rerr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+rowSavepoint); rerr != nil {
+		return false, true, err
+	}
+	return false, false, err
+}
+
+// Save upserts one incident, opening a transaction if none is open. In dry-run mode no
+// transaction is opened and rows are only counted. Each row is written under a
+// savepoint, so a failed row is rolled back alone and the rest of the batch still
+// commits. Only if the transaction itself is lost are the rows that had already
+// succeeded in it replayed into a fresh one. Transient errors are retried up to
+// upsertMaxAttempts times; otherwise the failure is returned and the store stays usable.
+func (s *postgresStore) Save(ctx context.Context, row enrichedIncident) error {
+	if dryRun {
+		_, err := saveToUnifiedDB(ctx, nil, row)
+		if err == nil {
+			s.saved++
+		}
+		return err
+	}
+	if s.tx == nil {
+		if err := s.begin(ctx); err != nil {
+			return err
+		}
+	}
+
+	var inserted bool
+	for attempt := 1; ; attempt++ {
+		var lost bool
+		var err error
+		inserted, lost, err = s.writeAtSavepoint(ctx, row)
+		if err == nil {
+			break
+		}
+		dbErrorsTotal.Inc()
+		if lost {
+			if rerr := s.replay(ctx); rerr != nil {
+				return fmt.Errorf("%w (and recovering the batch failed: %v)", err, rerr)
+			}
+		}
+		if attempt >= upsertMaxAttempts || !isRetryableDBError(err) {
+			return err
+		}
+		delay := time.Duration(attempt) * upsertRetryDelay
+		slog.WarnContext(ctx, "Retrying upsert after transient database error", "source_id", sourceIDFor(row.incident), "attempt", attempt, "retry_in", delay, "error", err)
+		time.Sleep(delay)
+	}
+
+	s.pending = append(s.pending, pendingRow{row, inserted})
+	if s.size > 0 && len(s.pending) >= s.size {
+		return s.commit()
+	}
+	return nil
+}
+
+// replay rolls back a lost transaction and re-executes its successful rows in a new one.
+// A row that fails on replay is dropped and the replay starts over without it.
+func (s *postgresStore) replay(ctx context.Context) error {
+	rows := s.pending
+	for {
+		s.tx.Rollback()
+		s.tx = nil
+		if err := s.begin(ctx); err != nil {
+			return err
+		}
+		failed := -1
+		for i, row := range rows {
+			inserted, err := s.write(ctx, row.enrichedIncident)
+			if err != nil {
+				dbErrorsTotal.Inc()
+				slog.Error("Dropping incident that failed on replay", "incident_address", row.incident.Address, "source_id", sourceIDFor(row.incident), "correlation_id", correlationID(row.incident), "error", err)
+				failed = i
+				break
+			}
+			row.inserted = inserted
+			s.pending = append(s.pending, row)
+		}
+		if failed < 0 {
+			return nil
+		}
+		rows = append(append([]pendingRow(nil), rows[:failed]...), rows[failed+1:]...)
+	}
+}
+
+// commit commits the open transaction. The next Save opens a new one.
+func (s *postgresStore) commit() error {
+	tx, rows := s.tx, s.pending
+	s.tx, s.stmt, s.historyStmt, s.pending = nil, nil, nil, nil
+	if err := tx.Commit(); err != nil {
+		dbErrorsTotal.Inc()
+		return fmt.Errorf("committing batch: %w", err)
+	}
+	s.saved += len(rows)
+	if s.secondary {
+		slog.Debug("Committed batch to secondary database", "rows", len(rows))
+		return nil
+	}
+	announceCommitted(rows)
+	return nil
+}
+
+// announceCommitted counts a committed batch and sends its webhooks and bus messages.
+// It is only called after the commit so a rolled-back insert is never announced.
+func announceCommitted(rows []pendingRow) {
+	incidentsSavedTotal.Add(float64(len(rows)))
+	slog.Info("Committed batch", "rows", len(rows))
+	for _, row := range rows {
+		if row.inserted {
+			notifyNewIncident(row.incident, row.weather)
+		}
+		publishIncident(row.enrichedIncident)
+	}
+}
+
+// Flush commits any open transaction and returns the rows committed since the last Flush.
+func (s *postgresStore) Flush(ctx context.Context) (int, error) {
+	var err error
+	if s.tx != nil {
+		err = s.commit()
+	}
+	saved := s.saved
+	s.saved = 0
+	return saved, err
+}
+
+// teeStore writes every incident to a primary store and a secondary one, such as an
+// analytics replica. Only the primary's errors and counts are reported; the secondary's
+// failures are logged so an analytics outage never stops operational ingestion.
+type teeStore struct {
+	primary, secondary IncidentStore
+}
+
+func (s teeStore) Save(ctx context.Context, row enrichedIncident) error {
+	err := s.primary.Save(ctx, row)
+	if serr := s.secondary.Save(ctx, row); serr != nil {
+		slog.WarnContext(ctx, "Error saving incident to secondary database", "source_id", sourceIDFor(row.incident), "error", serr)
+	}
+	return err
+}
+
+func (s teeStore) Flush(ctx context.Context) (int, error) {
+	saved, err := s.primary.Flush(ctx)
+	if _, serr := s.secondary.Flush(ctx); serr != nil {
+		slog.Warn("Error flushing secondary database", "error", serr)
+	}
+	return saved, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the ingestor's spans. Until setupTracing installs an exporting
+// provider, the global no-op provider makes every span free.
+var tracer = otel.Tracer("rwecc-ingestor-bot")
+
+// setupTracing exports spans over OTLP/HTTP to endpoint and returns a function that
+// flushes and stops the exporter. As with OTEL_EXPORTER_OTLP_ENDPOINT, endpoint is the
+// collector's base URL and traces go to its /v1/traces path. Other OTEL_EXPORTER_OTLP_*
+// variables (headers, timeout) are read by the exporter itself.
+func setupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OTLP endpoint: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName("rwecc-ingestor-bot"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// incidentAttributes identifies an incident on a span.
+func incidentAttributes(incident Incident) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attribute.String("incident.source", incidentSource(incident)),
+		attribute.String("incident.source_id", sourceIDFor(incident)),
+		attribute.String("incident.jurisdiction", incident.Jurisdiction),
+	)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
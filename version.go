@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, injected at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// build_ingester.sh does this. An unset commit falls back to the VCS revision Go
+// records in the binary.
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+func init() {
+	if commit != "unknown" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && s.Value != "" {
+				commit = s.Value
+			}
+		}
+	}
+}
+
+// versionString is what --version prints.
+func versionString() string {
+	return fmt.Sprintf("rwecc-ingestor-bot %s (commit %s, built %s, %s, details schema v%d)",
+		version, commit, buildDate, runtime.Version(), detailsSchemaVersion)
+}
+
+// logBuildInfo records which build is running, for correlating behavior with deploys.
+func logBuildInfo() {
+	slog.Info("Starting rwecc-ingestor-bot",
+		"version", version,
+		"commit", commit,
+		"build_date", buildDate,
+		"go_version", runtime.Version(),
+		"details_schema_version", detailsSchemaVersion)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// WeatherSource looks up the weather at a point and time.
+type WeatherSource interface {
+	Weather(ctx context.Context, lat, lon float64, at time.Time) (*WeatherData, error)
+}
+
+// weatherSource enriches every incident. Selected by WEATHER_SOURCE; the default is the
+// live NWS API.
+var weatherSource WeatherSource = nwsWeatherSource{}
+
+// historicalMaxSkew is how far an archived observation's time may be from the incident's
+// for it to count as the weather at that incident.
+const historicalMaxSkew = 90 * time.Minute
+
+// newWeatherSource parses WEATHER_SOURCE: "nws" for the live API, "file:<path>" for a
+// JSON archive of observations, or "db" for the historical_weather table.
+func newWeatherSource(spec string, db *sql.DB) (WeatherSource, error) {
+	switch {
+	case spec == "" || spec == "nws":
+		return nwsWeatherSource{}, nil
+	case strings.HasPrefix(spec, "file:"):
+		return loadFileWeatherSource(strings.TrimPrefix(spec, "file:"))
+	case spec == "db":
+		return dbWeatherSource{db}, nil
+	}
+	return nil, fmt.Errorf("unknown WEATHER_SOURCE %q (want \"nws\", \"file:<path>\", or \"db\")", spec)
+}
+
+// nwsWeatherSource is the live NWS forecast. It only knows the current hour, so the
+// requested time is ignored.
+type nwsWeatherSource struct{}
+
+func (nwsWeatherSource) Weather(ctx context.Context, lat, lon float64, _ time.Time) (*WeatherData, error) {
+	return getWeatherForIncident(ctx, lat, lon)
+}
+
+// historicalMaxDistance is how far, in km, an archived observation's point may be from
+// the incident for it to count as the weather there.
+const historicalMaxDistance = 1.5
+
+// kmPerDegree is the length of a degree of latitude, and of longitude at the equator.
+const kmPerDegree = 111.32
+
+// distanceKm approximates the distance between two nearby points with an
+// equirectangular projection, accurate to well under a percent at these ranges.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	x := (lon2 - lon1) * math.Cos((lat1+lat2)/2*math.Pi/180)
+	y := lat2 - lat1
+	return kmPerDegree * math.Hypot(x, y)
+}
+
+// historicalCellSize is the grid, in degrees, archived observations are indexed by.
+const historicalCellSize = 0.01
+
+// historicalCell is the index of the grid cell containing a coordinate.
+type historicalCell struct{ lat, lon int }
+
+func cellFor(lat, lon float64) historicalCell {
+	return historicalCell{int(math.Floor(lat / historicalCellSize)), int(math.Floor(lon / historicalCellSize))}
+}
+
+// historicalObservation is one archived reading in a file-backed weather source.
+type historicalObservation struct {
+	Lat     float64     `json:"lat"`
+	Lon     float64     `json:"lon"`
+	Time    time.Time   `json:"time"`
+	Weather WeatherData `json:"weather"`
+}
+
+// fileWeatherSource serves archived observations loaded from a JSON array of
+// historicalObservation, for backfilling incidents older than the NWS forecast.
+type fileWeatherSource struct {
+	byCell map[historicalCell][]historicalObservation
+}
+
+func loadFileWeatherSource(path string) (*fileWeatherSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading historical weather file: %w", err)
+	}
+	var observations []historicalObservation
+	if err := json.Unmarshal(data, &observations); err != nil {
+		return nil, fmt.Errorf("parsing historical weather file: %w", err)
+	}
+	src := &fileWeatherSource{byCell: make(map[historicalCell][]historicalObservation)}
+	for _, o := range observations {
+		cell := cellFor(o.Lat, o.Lon)
+		src.byCell[cell] = append(src.byCell[cell], o)
+	}
+	return src, nil
+}
+
+// Weather returns an observation from the nearest archived point within
+// historicalMaxDistance, the one closest in time to at, within historicalMaxSkew.
+// Anything else is reported as outside coverage.
+func (s *fileWeatherSource) Weather(_ context.Context, lat, lon float64, at time.Time) (*WeatherData, error) {
+	// Search every cell that could hold a point within historicalMaxDistance; cells
+	// narrow with latitude, so more of them are needed east and west.
+	latCells := int(math.Ceil(historicalMaxDistance / (kmPerDegree * historicalCellSize)))
+	lonCells := int(math.Ceil(historicalMaxDistance / (kmPerDegree * historicalCellSize * math.Max(math.Cos(lat*math.Pi/180), 0.01))))
+	center := cellFor(lat, lon)
+
+	var best *historicalObservation
+	bestDistance, bestSkew := math.Inf(1), time.Duration(math.MaxInt64)
+	for dy := -latCells; dy <= latCells; dy++ {
+		for dx := -lonCells; dx <= lonCells; dx++ {
+			observations := s.byCell[historicalCell{center.lat + dy, center.lon + dx}]
+			for i := range observations {
+				o := &observations[i]
+				distance, skew := distanceKm(lat, lon, o.Lat, o.Lon), o.Time.Sub(at).Abs()
+				if distance > historicalMaxDistance || skew > historicalMaxSkew {
+					continue
+				}
+				if distance < bestDistance || (distance == bestDistance && skew < bestSkew) {
+					best, bestDistance, bestSkew = o, distance, skew
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: no archived weather within %g km of %.4f,%.4f at %s", ErrOutsideCoverage, historicalMaxDistance, lat, lon, at.Format(time.RFC3339))
+	}
+	weather := best.Weather
+	return &weather, nil
+}
+
+// dbWeatherSource reads archived observations from the historical_weather table
+// (latitude, longitude, observed_at, data JSONB holding a WeatherData).
+type dbWeatherSource struct {
+	db *sql.DB
+}
+
+// historicalWeatherSQL picks, among observations within $5 km of ($1, $2) and within
+// $4 of $3, one from the nearest point, closest in time. The distance is the same
+// equirectangular approximation as distanceKm; $6 is kmPerDegree. The bounding box on
+// latitude lets an index on it narrow the scan.
+const historicalWeatherSQL = `
+	SELECT data FROM (
+		SELECT data, observed_at,
+			$6::float8 * sqrt(power(latitude - $1::float8, 2)
+				+ power((longitude - $2::float8) * cos(radians((latitude + $1::float8) / 2)), 2)) AS distance_km
+		FROM historical_weather
+		WHERE latitude BETWEEN $1::float8 - $5::float8 / $6::float8 AND $1::float8 + $5::float8 / $6::float8
+			AND observed_at BETWEEN $3::timestamptz - $4::interval AND $3::timestamptz + $4::interval
+	) nearby
+	WHERE distance_km <= $5::float8
+	ORDER BY distance_km, abs(extract(epoch FROM observed_at - $3::timestamptz))
+	LIMIT 1;
+`
+
+func (s dbWeatherSource) Weather(ctx context.Context, lat, lon float64, at time.Time) (*WeatherData, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, historicalWeatherSQL, lat, lon, at, fmt.Sprintf("%d seconds", int(historicalMaxSkew.Seconds())),
+		historicalMaxDistance, kmPerDegree).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no archived weather within %g km of %.4f,%.4f at %s", ErrOutsideCoverage, historicalMaxDistance, lat, lon, at.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying historical weather: %w", err)
+	}
+	var weather WeatherData
+	if err := json.Unmarshal(data, &weather); err != nil {
+		return nil, fmt.Errorf("parsing historical weather: %w", err)
+	}
+	return &weather, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWeatherSource(t *testing.T) {
+	at := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	observations := []historicalObservation{
+		// Just across the .785 rounding boundary from the incident, a few metres away.
+		{Lat: 35.7851, Lon: -78.6449, Time: at, Weather: WeatherData{ShortForecast: "Near"}},
+		// Farther away, though closer in time.
+		{Lat: 35.7900, Lon: -78.6449, Time: at.Add(-time.Minute), Weather: WeatherData{ShortForecast: "Far"}},
+		// Right at the incident, but too long before it.
+		{Lat: 35.7849, Lon: -78.6451, Time: at.Add(-3 * time.Hour), Weather: WeatherData{ShortForecast: "Stale"}},
+	}
+	data, err := json.Marshal(observations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "weather.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := loadFileWeatherSource(path)
+	if err != nil {
+		t.Fatalf("loadFileWeatherSource: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     string // ShortForecast; empty means outside coverage
+	}{
+		{name: "nearest point across a rounding boundary", lat: 35.7849, lon: -78.6451, want: "Near"},
+		{name: "within tolerance of the farther point only", lat: 35.7990, lon: -78.6449, want: "Far"},
+		{name: "beyond tolerance", lat: 35.8200, lon: -78.6449},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather, err := src.Weather(context.Background(), tt.lat, tt.lon, at)
+			if tt.want == "" {
+				if !errors.Is(err, ErrOutsideCoverage) {
+					t.Errorf("err = %v, want ErrOutsideCoverage", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Weather: %v", err)
+			}
+			if weather.ShortForecast != tt.want {
+				t.Errorf("ShortForecast = %q, want %q", weather.ShortForecast, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	// 0.01 degrees of latitude is about 1.11 km anywhere.
+	if d := distanceKm(35.78, -78.64, 35.79, -78.64); d < 1.10 || d > 1.12 {
+		t.Errorf("distanceKm over 0.01 degrees of latitude = %.3f, want about 1.11", d)
+	}
+	// 0.01 degrees of longitude shrinks with cos(latitude): about 0.90 km at 35.78 N.
+	if d := distanceKm(35.78, -78.64, 35.78, -78.63); d < 0.89 || d > 0.91 {
+		t.Errorf("distanceKm over 0.01 degrees of longitude = %.3f, want about 0.90", d)
+	}
+}
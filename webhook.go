@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhookURL receives a POST for every newly-inserted incident. Set by WEBHOOK_URL.
+var webhookURL string
+
+var (
+	webhookClient = &http.Client{Timeout: 10 * time.Second, Transport: outboundTransport}
+	webhookWG     sync.WaitGroup
+)
+
+// webhookPayload is the JSON body posted for a new incident.
+type webhookPayload struct {
+	Address      string       `json:"address"`
+	Jurisdiction string       `json:"jurisdiction"`
+	Problem      string       `json:"problem"`
+	Timestamp    string       `json:"timestamp"`
+	Weather      *WeatherData `json:"weather"`
+}
+
+// notifyNewIncident posts the incident to webhookURL in the background. Failures are
+// logged only; they never affect ingestion.
+func notifyNewIncident(incident Incident, weather *WeatherData) {
+	if webhookURL == "" {
+		return
+	}
+	var payload any = webhookPayload{
+		Address:      incident.Address,
+		Jurisdiction: incident.Jurisdiction,
+		Problem:      incident.Problem,
+		Timestamp:    incident.Timestamp,
+		Weather:      weather,
+	}
+	if notifyFormat == notifySlack {
+		payload = slackIncidentMessage(incident, weather)
+	}
+	webhookWG.Add(1)
+	go func() {
+		defer webhookWG.Done()
+		if err := postWebhook(payload); err != nil {
+			slog.Warn("Webhook notification failed", "incident_address", incident.Address, "source_id", sourceIDFor(incident), "correlation_id", correlationID(incident), "error", err)
+		}
+	}()
+}
+
+// waitForWebhooks blocks until in-flight notifications finish, so a one-shot run
+// doesn't exit before they are delivered.
+func waitForWebhooks() {
+	webhookWG.Wait()
+}
+
+func postWebhook(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling webhook payload: %w", err)
+	}
+	resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned non-2xx status: %s", resp.Status)
+	}
+	return nil
+}